@@ -449,7 +449,7 @@ func identifyLibraryFromAPI(checksum string) *LibraryInfo {
 	}
 
 	// Try multiple APIs concurrently
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
 	defer cancel()
 
 	resultChan := make(chan *LibraryInfo, 3)
@@ -768,49 +768,43 @@ func (fdb *FileChecksumDB) queryFileChecksumDB(checksum string) *LibraryInfo {
 
 // queryLocalDatabase checks if we have this checksum in our local database
 func queryLocalDatabase(ctx context.Context, checksum string) *LibraryInfo {
-	if db == nil {
+	if store == nil {
 		return nil
 	}
 
-	query := `
-		SELECT library_name, library_version, identified_by, checksum 
-		FROM scan_results 
-		WHERE checksum = ? AND library_name IS NOT NULL AND library_name != 'unknown' 
-		LIMIT 1
-	`
-	
-	var name, version, method, dbChecksum string
-	err := db.QueryRowContext(ctx, query, checksum).Scan(&name, &version, &method, &dbChecksum)
+	info, err := store.LookupChecksum(ctx, checksum)
 	if err != nil {
 		return nil
 	}
 
-	return &LibraryInfo{
-		Name:     name,
-		Version:  version,
-		Checksum: dbChecksum,
-		Method:   "local-db",
-	}
+	info.Method = "local-db"
+	return info
 }
 
 // identifyLibrary uses multiple strategies to identify a JavaScript library
 func identifyLibrary(scriptURL, checksum string, jsCode string) *LibraryInfo {
 	// Strategy 1: URL pattern analysis (fastest and most reliable for CDNs)
-	if info := identifyLibraryFromURL(scriptURL); info != nil {
-		info.Checksum = checksum
-		return info
+	if identificationSources[SourceURLPattern] {
+		if info := identifyLibraryFromURL(scriptURL); info != nil {
+			info.Checksum = checksum
+			return info
+		}
 	}
 
 	// Strategy 2: Code analysis for version and library signatures
-	if info := identifyLibraryFromCode(jsCode, scriptURL); info != nil {
-		info.Checksum = checksum
-		return info
+	if identificationSources[SourceCodeAnalysis] {
+		if info := identifyLibraryFromCode(jsCode, scriptURL); info != nil {
+			info.Checksum = checksum
+			return info
+		}
 	}
 
 	// Strategy 3: API lookup by checksum
-	if info := identifyLibraryFromAPI(checksum); info != nil {
-		// checksum already set by API functions
-		return info
+	if identificationSources[SourceChecksumLookup] {
+		if info := identifyLibraryFromAPI(checksum); info != nil {
+			// checksum already set by API functions
+			return info
+		}
 	}
 
 	// Fallback: Extract name from URL and mark as unknown version
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the batched database writer
+const (
+	defaultWriteBatchSize = 50
+	batchFlushInterval    = 2 * time.Second // Longest time a row waits in the buffer
+	batchWriteAttempts    = 3
+	batchRetryDelay       = 500 * time.Millisecond // Doubled after every failed attempt
+)
+
+// BatchWriter buffers scan results and reachability checks and stores them
+// with multi-row INSERTs. A buffer is written once it holds batchSize rows,
+// when the flush interval passes and on Close. Failed writes are retried;
+// rows that still cannot be stored are dropped and counted.
+type BatchWriter struct {
+	batchSize int
+
+	mu           sync.Mutex // Guards the buffers
+	results      []ScanResult
+	reachability []*URLReachability
+
+	writeMu sync.Mutex // Serializes writes to the database
+	dropped int64      // Accessed atomically
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBatchWriter starts a writer that stores up to batchSize rows per
+// INSERT and flushes buffered rows every flushInterval
+func NewBatchWriter(batchSize int, flushInterval time.Duration) *BatchWriter {
+	if batchSize <= 0 {
+		batchSize = defaultWriteBatchSize
+	}
+	w := &BatchWriter{
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.flushPeriodically(flushInterval)
+	return w
+}
+
+// AddResults buffers scan results, writing the buffer once it is full
+func (w *BatchWriter) AddResults(results ...ScanResult) {
+	if len(results) == 0 {
+		return
+	}
+	w.mu.Lock()
+	w.results = append(w.results, results...)
+	full := len(w.results) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		w.flush(false)
+	}
+}
+
+// AddReachability buffers a reachability check, writing the buffer once it
+// is full
+func (w *BatchWriter) AddReachability(result *URLReachability) {
+	w.mu.Lock()
+	w.reachability = append(w.reachability, result)
+	full := len(w.reachability) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		w.flush(false)
+	}
+}
+
+// Flush writes all buffered rows
+func (w *BatchWriter) Flush() {
+	w.flush(true)
+}
+
+// flush writes the buffered rows, or with all false only full batches,
+// leaving the remainder for a later flush
+func (w *BatchWriter) flush(all bool) {
+	w.mu.Lock()
+	results, rest := takeRows(w.results, w.batchSize, all)
+	w.results = rest
+	reachability, restReachability := takeRows(w.reachability, w.batchSize, all)
+	w.reachability = restReachability
+	w.mu.Unlock()
+
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	for start := 0; start < len(reachability); start += w.batchSize {
+		batch := reachability[start:min(start+w.batchSize, len(reachability))]
+		w.write("reachability checks", len(batch), func() error { return store.StoreURLReachabilities(batch) })
+	}
+	for start := 0; start < len(results); start += w.batchSize {
+		batch := results[start:min(start+w.batchSize, len(results))]
+		w.write("scan results", len(batch), func() error { return store.StoreResults(batch) })
+	}
+}
+
+// takeRows splits buffered rows into those to write now and those to keep:
+// all of them, or with all false only as many as fill whole batches
+func takeRows[T any](rows []T, batchSize int, all bool) (take, keep []T) {
+	n := len(rows)
+	if !all {
+		n -= n % batchSize
+	}
+	if n == len(rows) {
+		return rows, nil
+	}
+	return rows[:n], append([]T(nil), rows[n:]...)
+}
+
+// write runs a batch insert, retrying failures, and counts the rows as
+// dropped if every attempt fails
+func (w *BatchWriter) write(what string, rows int, insert func() error) {
+	delay := batchRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := insert()
+		if err == nil {
+			logger.Printf("Stored %d %s\n", rows, what)
+			return
+		}
+		if attempt == batchWriteAttempts {
+			logger.Printf("Dropping %d %s after %d failed attempts: %v\n", rows, what, attempt, err)
+			atomic.AddInt64(&w.dropped, int64(rows))
+			return
+		}
+		logger.Printf("Error storing %d %s (attempt %d of %d), retrying in %v: %v\n", rows, what, attempt, batchWriteAttempts, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// flushPeriodically writes buffered rows every interval until Close
+func (w *BatchWriter) flushPeriodically(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Close stops the flush timer and writes the remaining rows
+func (w *BatchWriter) Close() {
+	close(w.stop)
+	<-w.done
+	w.Flush()
+}
+
+// Dropped returns the number of rows that could not be stored
+func (w *BatchWriter) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
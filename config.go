@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is loaded when present and no -config is given
+const defaultConfigFile = "netweather.yaml"
+
+// Library identification sources that can be enabled in the configuration
+const (
+	SourceURLPattern     = "url-pattern"     // CDN URL patterns
+	SourceCodeAnalysis   = "code-analysis"   // Version comments and library signatures
+	SourceChecksumLookup = "checksum-lookup" // entries.db and external checksum APIs
+)
+
+// Settings that used to be hard-coded; the configuration file may change them
+var (
+	excludedDomains       = []string{"login.microsoftonline.com"} // Hostnames (and their subdomains) never scanned
+	reachabilityTimeout   = 15 * time.Second
+	redirectTimeout       = 10 * time.Second
+	scriptTimeout         time.Duration // Script downloads, 0 for no limit
+	lookupTimeout         = 10 * time.Second
+	maxRedirects          = 10
+	identificationSources = map[string]bool{SourceURLPattern: true, SourceCodeAnalysis: true, SourceChecksumLookup: true}
+)
+
+// Config is a netweather.yaml file. The top-level settings apply to every
+// invocation; a profile overrides the keys it sets.
+type Config struct {
+	Settings       `yaml:",inline"`
+	DefaultProfile string               `yaml:"default_profile"`
+	Profiles       map[string]yaml.Node `yaml:"profiles"`
+
+	root yaml.Node // Parsed document, decoded again for each profile
+}
+
+// Settings covers everything that can be configured in the file
+type Settings struct {
+	Database       DatabaseSettings       `yaml:"database"`
+	Parallelism    ParallelismSettings    `yaml:"parallelism"`
+	Timeouts       TimeoutSettings        `yaml:"timeouts"`
+	Exclusions     []string               `yaml:"exclusions"`
+	RulesFile      string                 `yaml:"rules_file"`
+	Discovery      DiscoverySettings      `yaml:"discovery"`
+	Crawl          CrawlSettings          `yaml:"crawl"`
+	ScriptCache    ScriptCacheSettings    `yaml:"script_cache"`
+	Identification IdentificationSettings `yaml:"identification"`
+	Output         OutputSettings         `yaml:"output"`
+	PortScan       PortScanSettings       `yaml:"port_scan"`
+	Verbose        *bool                  `yaml:"verbose"`
+	Listen         string                 `yaml:"listen"`
+}
+
+// DatabaseSettings mirror the -db flags
+type DatabaseSettings struct {
+	Enabled  *bool  `yaml:"enabled"`
+	Driver   string `yaml:"driver"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	Path     string `yaml:"path"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+// ParallelismSettings mirror the worker flags
+type ParallelismSettings struct {
+	Workers       int            `yaml:"workers"`
+	RequestDelay  *time.Duration `yaml:"request_delay"` // 0s disables the delay
+	BatchSize     int            `yaml:"batch_size"`
+	Sequential    *bool          `yaml:"sequential"`
+	ScriptWorkers int            `yaml:"script_workers"` // Scripts of a page fetched at once
+	ScriptFetches int            `yaml:"script_fetches"` // Script downloads in flight across all pages
+}
+
+// DiscoverySettings control sitemap-based page discovery
+type DiscoverySettings struct {
+	MaxPages *int `yaml:"max_pages"` // Pages per host, 0 disables discovery
+}
+
+// ScriptCacheSettings control the cache of downloaded scripts
+type ScriptCacheSettings struct {
+	SizeMB *int   `yaml:"size_mb"` // Memory for cached scripts, 0 disables the memory cache
+	Dir    string `yaml:"dir"`     // Directory keeping scripts between runs
+}
+
+// CrawlSettings control the same-origin link crawler
+type CrawlSettings struct {
+	Depth    *int `yaml:"depth"`     // Link levels, 0 disables crawling
+	MaxPages int  `yaml:"max_pages"` // Pages per site including the first
+}
+
+// TimeoutSettings replace the built-in HTTP timeouts and redirect limit
+type TimeoutSettings struct {
+	Reachability time.Duration `yaml:"reachability"`
+	Redirect     time.Duration `yaml:"redirect"`
+	Script       time.Duration `yaml:"script"`
+	Lookup       time.Duration `yaml:"lookup"`
+	Discovery    time.Duration `yaml:"discovery"`
+	MaxRedirects int           `yaml:"max_redirects"`
+}
+
+// IdentificationSettings select how libraries are identified
+type IdentificationSettings struct {
+	Sources  []string `yaml:"sources"`
+	RemoteDB *bool    `yaml:"remote_db"`
+	VulnDB   string   `yaml:"vuln_db"`
+}
+
+// OutputSettings mirror the output and SBOM flags
+type OutputSettings struct {
+	Format  string `yaml:"format"`
+	File    string `yaml:"file"`
+	SBOM    string `yaml:"sbom"`
+	SBOMDir string `yaml:"sbom_dir"`
+}
+
+// PortScanSettings mirror the port scan flags and locate the nmap service
+type PortScanSettings struct {
+	Enabled    *bool  `yaml:"enabled"`
+	Ports      string `yaml:"ports"`
+	Options    string `yaml:"options"`
+	ServiceURL string `yaml:"service_url"`
+	Workers    int    `yaml:"workers"`    // nmap batches running at once
+	BatchSize  int    `yaml:"batch_size"` // Hosts per nmap batch
+}
+
+// loadConfig parses a configuration file, rejecting unknown keys
+func loadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode once with the profiles typed as Settings so that unknown keys
+	// are reported with their line in the file
+	var strict struct {
+		Settings       `yaml:",inline"`
+		DefaultProfile string              `yaml:"default_profile"`
+		Profiles       map[string]Settings `yaml:"profiles"`
+	}
+	if err := strictDecode(content, &strict); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(content, &config.root); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if config.DefaultProfile != "" {
+		if _, exists := config.Profiles[config.DefaultProfile]; !exists {
+			return nil, fmt.Errorf("default_profile %q is not defined in %s", config.DefaultProfile, path)
+		}
+	}
+	return config, nil
+}
+
+// strictDecode decodes YAML into v and fails on keys v does not define
+func strictDecode(content []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// ProfileNames returns the defined profiles in lexical order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the top-level settings overridden by the named profile,
+// or by the default profile if name is empty
+func (c *Config) Resolve(name string) (*Settings, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+
+	var resolved Config
+	if err := c.root.Decode(&resolved); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return &resolved.Settings, nil
+	}
+
+	profile, exists := c.Profiles[name]
+	if !exists {
+		return nil, fmt.Errorf("profile %q is not defined (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	if err := profile.Decode(&resolved.Settings); err != nil {
+		return nil, fmt.Errorf("error applying profile %q: %v", name, err)
+	}
+	return &resolved.Settings, nil
+}
+
+// Validate checks values that the YAML types alone do not constrain
+func (s *Settings) Validate() error {
+	var problems []string
+	switch s.Database.Driver {
+	case "", "mysql", "postgres", "sqlite":
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver %q is not mysql, postgres or sqlite", s.Database.Driver))
+	}
+	switch strings.ToLower(s.Output.Format) {
+	case "", OutputJSON, OutputNDJSON, OutputCSV, OutputSARIF:
+	default:
+		problems = append(problems, fmt.Sprintf("output.format %q is not json, ndjson, csv or sarif", s.Output.Format))
+	}
+	switch strings.ToLower(s.Output.SBOM) {
+	case "", SBOMFormatCycloneDX, SBOMFormatSPDX:
+	default:
+		problems = append(problems, fmt.Sprintf("output.sbom %q is not %s or %s", s.Output.SBOM, SBOMFormatCycloneDX, SBOMFormatSPDX))
+	}
+	for _, source := range s.Identification.Sources {
+		if !knownIdentificationSource(source) {
+			problems = append(problems, fmt.Sprintf("identification.sources: unknown source %q (use %s, %s or %s)",
+				source, SourceURLPattern, SourceCodeAnalysis, SourceChecksumLookup))
+		}
+	}
+	for _, domain := range s.Exclusions {
+		if strings.TrimSpace(domain) == "" {
+			problems = append(problems, "exclusions: empty domain")
+		}
+	}
+	if s.RulesFile != "" {
+		if _, err := loadURLRules(s.RulesFile); err != nil {
+			problems = append(problems, fmt.Sprintf("rules_file: %v", err))
+		}
+	}
+	if s.Parallelism.Workers < 0 || s.Parallelism.BatchSize < 0 || s.Parallelism.ScriptWorkers < 0 || s.Parallelism.ScriptFetches < 0 ||
+		(s.Parallelism.RequestDelay != nil && *s.Parallelism.RequestDelay < 0) ||
+		(s.Discovery.MaxPages != nil && *s.Discovery.MaxPages < 0) ||
+		(s.Crawl.Depth != nil && *s.Crawl.Depth < 0) || s.Crawl.MaxPages < 0 ||
+		s.PortScan.Workers < 0 || s.PortScan.BatchSize < 0 {
+		problems = append(problems, "parallelism, discovery, crawl and port scan values must not be negative")
+	}
+	if s.ScriptCache.SizeMB != nil && *s.ScriptCache.SizeMB < 0 {
+		problems = append(problems, "script_cache.size_mb must not be negative")
+	}
+	if s.Timeouts.Reachability < 0 || s.Timeouts.Redirect < 0 || s.Timeouts.Script < 0 || s.Timeouts.Lookup < 0 || s.Timeouts.Discovery < 0 || s.Timeouts.MaxRedirects < 0 {
+		problems = append(problems, "timeouts must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// knownIdentificationSource reports whether source names an identification source
+func knownIdentificationSource(source string) bool {
+	switch source {
+	case SourceURLPattern, SourceCodeAnalysis, SourceChecksumLookup:
+		return true
+	}
+	return false
+}
+
+// configFlag links a command line flag to its environment variable and its
+// value in the configuration file
+type configFlag struct {
+	name   string
+	envKey string // Empty if the flag has no environment variable
+	value  string // Empty if the file does not set it
+}
+
+// formatBool renders an optional boolean for flag.Set
+func formatBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// formatOptionalInt renders an integer that may be set to 0 for flag.Set
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+// formatInt renders an optional integer for flag.Set
+func formatInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+// flagValues lists the flags the settings provide values for
+func (s *Settings) flagValues() []configFlag {
+	requestDelay := ""
+	if s.Parallelism.RequestDelay != nil {
+		requestDelay = strconv.FormatInt(s.Parallelism.RequestDelay.Milliseconds(), 10)
+	}
+	return []configFlag{
+		{"db", "", formatBool(s.Database.Enabled)},
+		{"db-driver", "DB_DRIVER", s.Database.Driver},
+		{"db-host", "DB_HOST", s.Database.Host},
+		{"db-port", "DB_PORT", s.Database.Port},
+		{"db-user", "DB_USER", s.Database.User},
+		{"db-password", "DB_PASSWORD", s.Database.Password},
+		{"db-name", "DB_NAME", s.Database.Name},
+		{"db-path", "DB_PATH", s.Database.Path},
+		{"db-sslmode", "DB_SSLMODE", s.Database.SSLMode},
+		{"workers", "", formatInt(s.Parallelism.Workers)},
+		{"request-delay", "", requestDelay},
+		{"batch-size", "", formatInt(s.Parallelism.BatchSize)},
+		{"sequential", "", formatBool(s.Parallelism.Sequential)},
+		{"script-workers", "", formatInt(s.Parallelism.ScriptWorkers)},
+		{"script-fetches", "", formatInt(s.Parallelism.ScriptFetches)},
+		{"script-cache-mb", "", formatOptionalInt(s.ScriptCache.SizeMB)},
+		{"script-cache-dir", "", s.ScriptCache.Dir},
+		{"remote-db", "", formatBool(s.Identification.RemoteDB)},
+		{"vuln-db", "VULN_DB", s.Identification.VulnDB},
+		{"output", "", s.Output.Format},
+		{"output-file", "", s.Output.File},
+		{"sbom", "", s.Output.SBOM},
+		{"sbom-dir", "", s.Output.SBOMDir},
+		{"port-scan", "", formatBool(s.PortScan.Enabled)},
+		{"scan-ports", "", s.PortScan.Ports},
+		{"nmap-options", "", s.PortScan.Options},
+		{"port-scan-workers", "", formatInt(s.PortScan.Workers)},
+		{"port-scan-batch", "", formatInt(s.PortScan.BatchSize)},
+		{"verbose", "", formatBool(s.Verbose)},
+		{"listen", "LISTEN_ADDR", s.Listen},
+		{"rules", "", s.RulesFile},
+		{"discover-pages", "", formatOptionalInt(s.Discovery.MaxPages)},
+		{"crawl-depth", "", formatOptionalInt(s.Crawl.Depth)},
+		{"crawl-pages", "", formatInt(s.Crawl.MaxPages)},
+	}
+}
+
+// Apply sets flags that were neither given on the command line nor through
+// their environment variable, so precedence is flags > env > file > defaults,
+// and replaces the built-in exclusions, timeouts and identification sources
+func (s *Settings) Apply(flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for _, f := range s.flagValues() {
+		if f.value == "" || explicit[f.name] {
+			continue
+		}
+		if f.envKey != "" && os.Getenv(f.envKey) != "" {
+			continue
+		}
+		if err := flags.Set(f.name, f.value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", f.value, f.name, err)
+		}
+	}
+
+	if len(s.Exclusions) > 0 {
+		excludedDomains = s.Exclusions
+	}
+	if s.Timeouts.Reachability > 0 {
+		reachabilityTimeout = s.Timeouts.Reachability
+	}
+	if s.Timeouts.Redirect > 0 {
+		redirectTimeout = s.Timeouts.Redirect
+	}
+	if s.Timeouts.Script > 0 {
+		scriptTimeout = s.Timeouts.Script
+	}
+	if s.Timeouts.Lookup > 0 {
+		lookupTimeout = s.Timeouts.Lookup
+	}
+	if s.Timeouts.Discovery > 0 {
+		discoveryTimeout = s.Timeouts.Discovery
+	}
+	if s.Timeouts.MaxRedirects > 0 {
+		maxRedirects = s.Timeouts.MaxRedirects
+	}
+	if len(s.Identification.Sources) > 0 {
+		identificationSources = make(map[string]bool)
+		for _, source := range s.Identification.Sources {
+			identificationSources[source] = true
+		}
+	}
+	if s.PortScan.ServiceURL != "" {
+		nmapServiceURL = strings.TrimSuffix(s.PortScan.ServiceURL, "/")
+	}
+	return nil
+}
+
+// configPath returns the configuration file to use: -config, then
+// NETWEATHER_CONFIG, then netweather.yaml if it exists. An empty result means
+// no file is used.
+func configPath(flagValue string) string {
+	if path := getConfigValue(flagValue, "NETWEATHER_CONFIG", ""); path != "" {
+		return path
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
+// applyConfigFile loads the configuration file and applies the selected
+// profile to the command line flags
+func applyConfigFile(path, profile string) error {
+	if path == "" {
+		if profile != "" {
+			return fmt.Errorf("profile %q requested but no configuration file found", profile)
+		}
+		return nil
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	settings, err := config.Resolve(profile)
+	if err != nil {
+		return err
+	}
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if err := settings.Apply(flag.CommandLine); err != nil {
+		return fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+
+	if profile == "" {
+		profile = config.DefaultProfile
+	}
+	if profile == "" {
+		profile = "none"
+	}
+	logger.Printf("Loaded configuration %s (profile: %s)\n", path, profile)
+	return nil
+}
+
+// runConfigCommand implements "netweather config validate [file]" and
+// returns the process exit code
+func runConfigCommand(args []string, path string) int {
+	if len(args) < 1 || args[0] != "validate" || len(args) > 2 {
+		fmt.Println("Usage: netweather config validate [netweather.yaml]")
+		return 1
+	}
+	if len(args) == 2 {
+		path = args[1]
+	}
+	if path == "" {
+		fmt.Printf("No configuration file given and %s not found\n", defaultConfigFile)
+		return 1
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		fmt.Printf("Invalid: %v\n", err)
+		return 1
+	}
+
+	valid := true
+	for _, name := range append([]string{""}, config.ProfileNames()...) {
+		label := "top-level settings"
+		var settings *Settings
+		if name == "" {
+			settings = &config.Settings
+		} else {
+			label = fmt.Sprintf("profile %q", name)
+			if settings, err = config.Resolve(name); err != nil {
+				fmt.Printf("Invalid %s: %v\n", label, err)
+				valid = false
+				continue
+			}
+		}
+		if err := settings.Validate(); err != nil {
+			fmt.Printf("Invalid %s: %v\n", label, err)
+			valid = false
+		}
+	}
+	if !valid {
+		return 1
+	}
+
+	fmt.Printf("Configuration %s is valid\n", path)
+	if names := config.ProfileNames(); len(names) > 0 {
+		fmt.Printf("Profiles: %s", strings.Join(names, ", "))
+		if config.DefaultProfile != "" {
+			fmt.Printf(" (default: %s)", config.DefaultProfile)
+		}
+		fmt.Println()
+	}
+	return 0
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// defaultCrawlPages is the page budget per site when only a depth is given
+const defaultCrawlPages = 20
+
+// crawlSkipExtensions are link targets that are never HTML pages
+var crawlSkipExtensions = map[string]bool{
+	".pdf": true, ".zip": true, ".gz": true, ".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".svg": true, ".webp": true, ".ico": true, ".css": true, ".js": true, ".json": true, ".xml": true,
+	".mp3": true, ".mp4": true, ".avi": true, ".mov": true, ".doc": true, ".docx": true, ".xls": true,
+	".xlsx": true, ".ppt": true, ".pptx": true, ".exe": true, ".dmg": true, ".woff": true, ".woff2": true,
+}
+
+// CrawlConfig controls the same-origin link crawler. With a depth of 0 only
+// the page itself is scanned.
+type CrawlConfig struct {
+	Depth    int           // Link levels to follow from the scanned page
+	MaxPages int           // Pages to scan per site including the first, 0 for defaultCrawlPages
+	Delay    time.Duration // Pause between pages of a site, the -request-delay
+}
+
+// crawlRun is shared by the crawls of one scan run: every page is scanned
+// once per run, however many sites link to it, and robots.txt is fetched
+// once per site
+type crawlRun struct {
+	mu      sync.Mutex
+	scanned map[string]bool         // Normalized URLs of pages scanned or queued as jobs
+	robots  map[string]*robotsEntry // By scheme://host
+}
+
+// robotsEntry is the robots.txt of a site, loaded on first use
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsTxt
+}
+
+// newCrawlRun creates the crawl state of a run. The pages of the jobs are
+// scanned by their own jobs and are never crawled.
+func newCrawlRun(jobs []URLJob) *crawlRun {
+	run := &crawlRun{scanned: make(map[string]bool), robots: make(map[string]*robotsEntry)}
+	for _, job := range jobs {
+		if strings.Contains(job.URL, "://") {
+			run.scanned[normalizePageURL(job.URL)] = true
+			continue
+		}
+		// The reachability check picks the protocol later
+		run.scanned[normalizePageURL("http://"+job.URL)] = true
+		run.scanned[normalizePageURL("https://"+job.URL)] = true
+	}
+	return run
+}
+
+// claim records a page as scanned and reports whether it was not already
+func (r *crawlRun) claim(pageURL string) bool {
+	key := normalizePageURL(pageURL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scanned[key] {
+		return false
+	}
+	r.scanned[key] = true
+	return true
+}
+
+// allowed reports whether robots.txt of the page's site permits fetching it
+func (r *crawlRun) allowed(ctx context.Context, page *url.URL) bool {
+	origin := strings.ToLower(page.Scheme + "://" + page.Host)
+	r.mu.Lock()
+	entry, exists := r.robots[origin]
+	if !exists {
+		entry = &robotsEntry{}
+		r.robots[origin] = entry
+	}
+	r.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = fetchRobotsTxt(ctx, &url.URL{Scheme: page.Scheme, Host: page.Host})
+	})
+	return entry.rules.Allowed(page)
+}
+
+// normalizePageURL returns the form of a page URL used to recognise pages
+// already scanned: lowercase scheme and host, no default port, no fragment,
+// / for an empty path and the directory for its index.html
+func normalizePageURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		u.Host = u.Host[:strings.LastIndex(u.Host, ":")]
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	for _, index := range []string{"index.html", "index.htm"} {
+		if strings.HasSuffix(u.Path, "/"+index) {
+			u.Path = strings.TrimSuffix(u.Path, index)
+			u.RawPath = ""
+		}
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// crawlPage is a page waiting to be fetched
+type crawlPage struct {
+	url   string
+	depth int
+}
+
+// fetchPage downloads and parses an HTML page within reachabilityTimeout.
+// It returns the URL the page was served from after redirects. Unless
+// requireHTML is false, responses that are not HTML are rejected.
+func fetchPage(ctx context.Context, pageURL string, requireHTML bool) (*html.Node, *url.URL, error) {
+	logger.Printf("Fetching URL %s\n", pageURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{
+		Timeout: reachabilityTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if requireHTML {
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+			return nil, nil, fmt.Errorf("not an HTML page (%s)", contentType)
+		}
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing HTML: %v", err)
+	}
+	return doc, resp.Request.URL, nil
+}
+
+// collectPageLinks returns the targets of the page's <a href> links that
+// share the origin of root, without fragments
+func collectPageLinks(doc *html.Node, page, root *url.URL) []string {
+	var links []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				target, err := page.Parse(strings.TrimSpace(a.Val))
+				if err != nil || !sameOrigin(target, root) {
+					continue
+				}
+				if crawlSkipExtensions[strings.ToLower(path.Ext(target.Path))] {
+					continue
+				}
+				target.Fragment = ""
+				target.RawFragment = ""
+				links = append(links, target.String())
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return links
+}
+
+// sameOrigin reports whether two URLs share scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// crawlSite calls visit for rootURL and, breadth-first, for same-origin pages
+// linked from it up to config.Depth links away, until config.MaxPages pages
+// were visited, waiting config.Delay before each linked page. Linked pages
+// are visited under the URL they were served from, once per run; pages
+// excluded by rules or robots.txt are skipped. The error is that of
+// fetching rootURL itself, failures of linked pages are only logged.
+func crawlSite(ctx context.Context, rootURL string, config CrawlConfig, run *crawlRun, visit func(pageURL string, doc *html.Node)) error {
+	maxPages := config.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultCrawlPages
+	}
+
+	doc, finalRoot, err := fetchPage(ctx, rootURL, false)
+	if err != nil {
+		return err
+	}
+	visit(rootURL, doc)
+	if config.Depth <= 0 {
+		return nil
+	}
+	if run == nil {
+		run = newCrawlRun(nil)
+	}
+	run.claim(rootURL)
+	run.claim(finalRoot.String())
+
+	seen := map[string]bool{rootURL: true, finalRoot.String(): true}
+	visited := 1
+	var queue []crawlPage
+	enqueue := func(doc *html.Node, page *url.URL, depth int) {
+		for _, link := range collectPageLinks(doc, page, finalRoot) {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			if rule := exclusionRule(link); rule != "" {
+				logger.Printf("Not crawling excluded page %s (rule: %s)\n", link, rule)
+				continue
+			}
+			if target, err := url.Parse(link); err != nil || !run.allowed(ctx, target) {
+				logger.Printf("Not crawling %s: disallowed by robots.txt\n", link)
+				continue
+			}
+			queue = append(queue, crawlPage{url: link, depth: depth})
+		}
+	}
+	enqueue(doc, finalRoot, 1)
+
+	for len(queue) > 0 && visited < maxPages {
+		if config.Delay > 0 {
+			select {
+			case <-time.After(config.Delay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		next := queue[0]
+		queue = queue[1:]
+		if !run.claim(next.url) {
+			continue
+		}
+
+		doc, final, err := fetchPage(ctx, next.url, true)
+		if err != nil {
+			logger.Printf("Skipping crawled page %s: %v\n", next.url, err)
+			continue
+		}
+		// Redirects may leave the site or land on a page already scanned
+		if !sameOrigin(final, finalRoot) {
+			continue
+		}
+		if final.String() != next.url && !run.claim(final.String()) {
+			continue
+		}
+		seen[final.String()] = true
+		visited++
+		visit(final.String(), doc)
+
+		if next.depth < config.Depth {
+			enqueue(doc, final, next.depth+1)
+		}
+	}
+	logger.Printf("Crawled %d pages of %s\n", visited, rootURL)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestNormalizePageURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://example.com", "http://example.com/"},
+		{"HTTP://Example.COM/Page", "http://example.com/Page"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"http://example.com/a#top", "http://example.com/a"},
+		{"http://example.com/index.html", "http://example.com/"},
+		{"http://example.com/docs/index.htm", "http://example.com/docs/"},
+		{"http://example.com/a?q=1", "http://example.com/a?q=1"},
+	}
+	for _, tt := range tests {
+		if got := normalizePageURL(tt.in); got != tt.want {
+			t.Errorf("normalizePageURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCrawlRunClaim(t *testing.T) {
+	run := newCrawlRun([]URLJob{{URL: "example.com"}, {URL: "https://other.example/about.html"}})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"http://example.com/", false},
+		{"https://example.com/index.html", false},
+		{"https://other.example/about.html#team", false},
+		{"https://other.example/contact.html", true},
+		{"https://other.example/contact.html", false},
+	}
+	for _, tt := range tests {
+		if got := run.claim(tt.url); got != tt.want {
+			t.Errorf("claim(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultScheduleTimeout bounds a scheduled run unless the schedule sets its own timeout
+const defaultScheduleTimeout = 30 * time.Minute
+
+// DaemonConfig is the schedule file of "netweather daemon"
+type DaemonConfig struct {
+	Listen    string           `yaml:"listen"` // Address of the health endpoint
+	Schedules []ScheduleConfig `yaml:"schedules"`
+}
+
+// ScheduleConfig runs one URL list on a cron schedule
+type ScheduleConfig struct {
+	Name          string         `yaml:"name"`
+	Cron          string         `yaml:"cron"` // Standard 5-field expression or a descriptor such as @daily
+	URLs          string         `yaml:"urls"` // URL list file (text, CSV or JSON), re-read on every run
+	Workers       int            `yaml:"workers"`
+	RequestDelay  *time.Duration `yaml:"request_delay"` // Default -request-delay, 0s disables the delay
+	Timeout       time.Duration  `yaml:"timeout"`
+	DiscoverPages *int           `yaml:"discover_pages"` // Pages per host from sitemaps, default -discover-pages, 0 disables discovery
+}
+
+// loadDaemonConfig reads and validates a schedule file
+func loadDaemonConfig(path string) (*DaemonConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var config DaemonConfig
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	if len(config.Schedules) == 0 {
+		return nil, fmt.Errorf("%s defines no schedules", path)
+	}
+	names := make(map[string]bool)
+	for i, schedule := range config.Schedules {
+		if schedule.Name == "" {
+			return nil, fmt.Errorf("schedule %d has no name", i+1)
+		}
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("duplicate schedule name %q", schedule.Name)
+		}
+		names[schedule.Name] = true
+		if schedule.URLs == "" {
+			return nil, fmt.Errorf("schedule %q has no urls file", schedule.Name)
+		}
+		if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+			return nil, fmt.Errorf("schedule %q has an invalid cron expression %q: %v", schedule.Name, schedule.Cron, err)
+		}
+		if (schedule.RequestDelay != nil && *schedule.RequestDelay < 0) || (schedule.DiscoverPages != nil && *schedule.DiscoverPages < 0) {
+			return nil, fmt.Errorf("schedule %q: request_delay and discover_pages must not be negative", schedule.Name)
+		}
+	}
+	return &config, nil
+}
+
+// scheduleState tracks the runs of one schedule
+type scheduleState struct {
+	config  ScheduleConfig
+	entryID cron.EntryID
+
+	mu           sync.Mutex
+	running      bool
+	runs         int
+	skipped      int // Runs skipped because the previous run was still active
+	lastRunID    int64
+	lastStarted  *time.Time
+	lastFinished *time.Time
+	lastError    string
+	lastCounts   [5]int64 // processed, scanned, excluded, skipped, errors
+}
+
+// scheduleStatus is the health endpoint representation of a schedule
+type scheduleStatus struct {
+	Name         string     `json:"name"`
+	Cron         string     `json:"cron"`
+	URLs         string     `json:"urls"`
+	Running      bool       `json:"running"`
+	Runs         int        `json:"runs"`
+	Skipped      int        `json:"skipped_overlapping"`
+	NextRun      time.Time  `json:"next_run"`
+	LastRunID    int64      `json:"last_run_id,omitempty"`
+	LastStarted  *time.Time `json:"last_started,omitempty"`
+	LastFinished *time.Time `json:"last_finished,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Processed    int64      `json:"last_processed"`
+	Scanned      int64      `json:"last_scanned"`
+	Errors       int64      `json:"last_errors"`
+}
+
+// Daemon runs URL lists on their schedules
+type Daemon struct {
+	defaults  ServeConfig
+	schedules []*scheduleState
+	cron      *cron.Cron
+	ctx       context.Context
+	started   time.Time
+}
+
+// NewDaemon creates a daemon for the given schedules; defaults supply the
+// worker count and request delay of schedules that do not set them
+func NewDaemon(config *DaemonConfig, defaults ServeConfig) (*Daemon, error) {
+	d := &Daemon{defaults: defaults, cron: cron.New()}
+	for _, schedule := range config.Schedules {
+		state := &scheduleState{config: schedule}
+		id, err := d.cron.AddFunc(schedule.Cron, func() { d.trigger(state) })
+		if err != nil {
+			return nil, fmt.Errorf("error scheduling %q: %v", schedule.Name, err)
+		}
+		state.entryID = id
+		d.schedules = append(d.schedules, state)
+	}
+	return d, nil
+}
+
+// Start begins running schedules until the context is cancelled
+func (d *Daemon) Start(ctx context.Context) {
+	d.ctx = ctx
+	d.started = time.Now()
+	d.cron.Start()
+}
+
+// Stop stops scheduling and waits for active runs to finish
+func (d *Daemon) Stop() {
+	<-d.cron.Stop().Done()
+}
+
+// trigger starts a run of the schedule unless its previous run is still
+// active; overlapping runs are skipped rather than queued
+func (d *Daemon) trigger(state *scheduleState) {
+	state.mu.Lock()
+	if state.running {
+		state.skipped++
+		state.mu.Unlock()
+		logger.Printf("Skipping run of schedule %s: previous run still active\n", state.config.Name)
+		return
+	}
+	startedAt := time.Now()
+	state.running = true
+	state.lastStarted = &startedAt
+	state.mu.Unlock()
+
+	runID, counts, err := d.runSchedule(state.config, startedAt)
+
+	finishedAt := time.Now()
+	state.mu.Lock()
+	state.running = false
+	state.runs++
+	state.lastRunID = runID
+	state.lastFinished = &finishedAt
+	state.lastCounts = counts
+	state.lastError = ""
+	if err != nil {
+		state.lastError = err.Error()
+	}
+	state.mu.Unlock()
+}
+
+// runSchedule processes the URL list of a schedule as a recorded scan run
+func (d *Daemon) runSchedule(schedule ScheduleConfig, startedAt time.Time) (int64, [5]int64, error) {
+	var counts [5]int64
+	logger.Printf("Starting scheduled run %s of %s\n", schedule.Name, schedule.URLs)
+
+	jobs, err := readURLInput(schedule.URLs)
+	if err != nil {
+		logger.Printf("Error reading URL list of schedule %s: %v\n", schedule.Name, err)
+		return 0, counts, err
+	}
+	pagesPerHost := d.defaults.DiscoverPages
+	if schedule.DiscoverPages != nil {
+		pagesPerHost = *schedule.DiscoverPages
+	}
+
+	workers := schedule.Workers
+	if workers <= 0 {
+		workers = d.defaults.MaxWorkers
+	}
+	requestDelay := d.defaults.RequestDelay
+	if schedule.RequestDelay != nil {
+		requestDelay = *schedule.RequestDelay
+	}
+	timeout := schedule.Timeout
+	if timeout <= 0 {
+		timeout = defaultScheduleTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
+	defer cancel()
+	jobs = discoverPages(ctx, jobs, pagesPerHost, workers)
+
+	run := &ScanRun{
+		StartedAt: startedAt,
+		InputFile: schedule.URLs,
+		Flags:     strings.TrimSpace(fmt.Sprintf("-schedule=%s %s", schedule.Name, explicitFlags())),
+		Workers:   workers,
+		TotalURLs: int64(len(jobs)),
+	}
+	if err := startScanRun(run); err != nil {
+		logger.Printf("Error recording scan run of schedule %s: %v\n", schedule.Name, err)
+		run = nil
+	}
+
+	config := ParallelConfig{
+		MaxWorkers:   workers,
+		RequestDelay: requestDelay,
+		BatchSize:    d.defaults.BatchSize,
+		UseDB:        true,
+		Quiet:        true,
+		Crawl:        d.defaults.Crawl,
+	}
+	if run != nil {
+		config.RunID = run.ID
+	}
+	processor := NewParallelProcessor(config)
+	err = processor.ProcessJobs(ctx, jobs)
+	if err == nil {
+		err = ctx.Err()
+	}
+	counts[0], counts[1], counts[2], counts[3], counts[4] = processor.Counts()
+
+	if run != nil {
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Processed, run.Scanned, run.Excluded, run.Skipped, run.Errors = counts[0], counts[1], counts[2], counts[3], counts[4]
+		if err := finishScanRun(run); err != nil {
+			logger.Printf("Error recording end of scan run %d: %v\n", run.ID, err)
+		}
+	}
+
+	if err != nil {
+		logger.Printf("Scheduled run %s stopped: %v\n", schedule.Name, err)
+	} else {
+		logger.Printf("Scheduled run %s finished: %d processed, %d scanned, %d errors\n", schedule.Name, counts[0], counts[1], counts[4])
+	}
+	return config.RunID, counts, err
+}
+
+// Status returns the state of every schedule
+func (d *Daemon) Status() []scheduleStatus {
+	statuses := make([]scheduleStatus, 0, len(d.schedules))
+	for _, state := range d.schedules {
+		state.mu.Lock()
+		statuses = append(statuses, scheduleStatus{
+			Name:         state.config.Name,
+			Cron:         state.config.Cron,
+			URLs:         state.config.URLs,
+			Running:      state.running,
+			Runs:         state.runs,
+			Skipped:      state.skipped,
+			NextRun:      d.cron.Entry(state.entryID).Next,
+			LastRunID:    state.lastRunID,
+			LastStarted:  state.lastStarted,
+			LastFinished: state.lastFinished,
+			LastError:    state.lastError,
+			Processed:    state.lastCounts[0],
+			Scanned:      state.lastCounts[1],
+			Errors:       state.lastCounts[4],
+		})
+		state.mu.Unlock()
+	}
+	return statuses
+}
+
+// healthHandler reports that the daemon is up together with its schedules
+func (d *Daemon) healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "healthy",
+		"started_at": d.started,
+		"schedules":  d.Status(),
+	})
+}
+
+// runDaemonCommand implements "netweather daemon <schedule.yaml>" and
+// returns the process exit code once the daemon has shut down
+func runDaemonCommand(args []string, defaults ServeConfig) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: netweather daemon [db-options] [-listen addr] <schedule.yaml>")
+		return 1
+	}
+
+	config, err := loadDaemonConfig(args[0])
+	if err != nil {
+		fmt.Printf("Error loading schedule: %v\n", err)
+		return 1
+	}
+	listen := defaults.Listen
+	if listen == "" {
+		listen = config.Listen
+	}
+	if listen == "" {
+		listen = defaultListenAddr
+	}
+
+	daemon, err := NewDaemon(config, defaults)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	daemon.Start(ctx)
+	for _, status := range daemon.Status() {
+		fmt.Printf("Schedule %s (%s): %s, next run %s\n", status.Name, status.Cron, status.URLs, status.NextRun.Format("2006-01-02 15:04:05"))
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/health", daemon.healthHandler).Methods("GET")
+	httpServer := &http.Server{
+		Addr:              listen,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Printf("Error shutting down health endpoint: %v\n", err)
+		}
+	}()
+
+	logger.Printf("Daemon started with %d schedules, health endpoint on %s\n", len(config.Schedules), listen)
+	fmt.Printf("Health endpoint listening on %s\n", listen)
+	exitCode := 0
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error running health endpoint: %v\n", err)
+		stop()
+		exitCode = 1
+	}
+
+	fmt.Println("Stopping daemon, waiting for active runs...")
+	daemon.Stop()
+	logger.Println("Daemon stopped")
+	return exitCode
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadDaemonConfigExplicitZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.yaml")
+	schedule := `schedules:
+  - name: unset
+    cron: "@daily"
+    urls: urls.txt
+  - name: zero
+    cron: "@daily"
+    urls: urls.txt
+    request_delay: 0s
+    discover_pages: 0
+  - name: set
+    cron: "@daily"
+    urls: urls.txt
+    request_delay: 250ms
+    discover_pages: 5
+`
+	if err := os.WriteFile(path, []byte(schedule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadDaemonConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		wantDelay *time.Duration
+		wantPages *int
+	}{
+		{"unset", nil, nil},
+		{"zero", durationPtr(0), intPtr(0)},
+		{"set", durationPtr(250 * time.Millisecond), intPtr(5)},
+	}
+	for i, tt := range tests {
+		got := config.Schedules[i]
+		if !equalPtr(got.RequestDelay, tt.wantDelay) || !equalPtr(got.DiscoverPages, tt.wantPages) {
+			t.Errorf("schedule %s: request_delay %v, discover_pages %v", tt.name, got.RequestDelay, got.DiscoverPages)
+		}
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration { return &d }
+
+func intPtr(n int) *int { return &n }
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// dashboardTemplates holds the pages of the web dashboard; each page
+// defines a "content" template rendered into layout.html
+//
+//go:embed templates/dashboard
+var dashboardTemplates embed.FS
+
+// Reachability filters of the dashboard
+const (
+	reachBoth        = "both"
+	reachHTTPSOnly   = "https"
+	reachHTTPOnly    = "http"
+	reachUnreachable = "unreachable"
+	reachRedirect    = "redirect"
+)
+
+// dashboardRunLimit caps the scan runs offered by the run selector and the
+// scans page
+const dashboardRunLimit = 50
+
+// dashboardPage is the data passed to every dashboard page
+type dashboardPage struct {
+	Title  string
+	Active string // Navigation entry to highlight
+	RunID  int64
+	Runs   []ScanRun
+	Query  url.Values
+	Data   interface{}
+}
+
+// dashboardSite summarizes the scripts of one site
+type dashboardSite struct {
+	URL         string
+	Owner       string
+	Tags        []string
+	Scripts     []ScanResult
+	Libraries   []string // "name version" of each identified library
+	Vulnerable  int
+	LastScanned time.Time
+}
+
+// dashboardLibrary summarizes one library across sites
+type dashboardLibrary struct {
+	Name       string
+	Versions   []string
+	Sites      int
+	Vulnerable int // Sites loading a version with known advisories
+}
+
+// dashboardVersion lists the sites using one version of a library
+type dashboardVersion struct {
+	Library         string
+	Version         string
+	Sites           []string
+	Checksums       int
+	Vulnerabilities []Vulnerability
+}
+
+// dashboardOverview is the data of the overview page
+type dashboardOverview struct {
+	Stats        *Statistics
+	Reachability *URLReachabilityStats
+	Vulnerable   []VulnerableLibrary
+	Libraries    []reportLibrary
+	Batches      map[string]int
+	Sites        int
+}
+
+// dashboardReachability is the data of the reachability page
+type dashboardReachability struct {
+	Reachability *URLReachabilityStats
+	Checks       []URLReachability
+	State        string
+}
+
+// dashboardFuncs are the helpers available to the dashboard templates
+var dashboardFuncs = template.FuncMap{
+	"percent": reportFuncs["percent"],
+	"vulns":   formatVulnerabilities,
+	"short": func(checksum string) string {
+		if len(checksum) > 12 {
+			return checksum[:12] + "…"
+		}
+		return checksum
+	},
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"describeRun": describeScanRun,
+	// link builds a dashboard URL that keeps the selected scan run
+	"link": func(path string, runID int64, pairs ...string) string {
+		values := url.Values{}
+		if runID != 0 {
+			values.Set("run", strconv.FormatInt(runID, 10))
+		}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			values.Set(pairs[i], pairs[i+1])
+		}
+		if len(values) == 0 {
+			return path
+		}
+		return path + "?" + values.Encode()
+	},
+}
+
+// dashboardPages maps page names to their parsed templates
+var dashboardPages map[string]*template.Template
+
+// loadDashboardTemplates parses the layout together with each page
+func loadDashboardTemplates() error {
+	layout, err := template.New("layout.html").Funcs(dashboardFuncs).ParseFS(dashboardTemplates, "templates/dashboard/layout.html")
+	if err != nil {
+		return err
+	}
+
+	pages := []string{"overview", "sites", "site", "libraries", "versions", "scans", "reachability", "unavailable"}
+	dashboardPages = make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		tmpl, err := template.Must(layout.Clone()).ParseFS(dashboardTemplates, "templates/dashboard/"+page+".html")
+		if err != nil {
+			return fmt.Errorf("error parsing dashboard page %s: %v", page, err)
+		}
+		dashboardPages[page] = tmpl
+	}
+	return nil
+}
+
+// registerDashboard adds the dashboard pages to the router
+func registerDashboard(r *mux.Router) error {
+	if err := loadDashboardTemplates(); err != nil {
+		return err
+	}
+	r.HandleFunc("/", dashboardHandler("overview", "Overview", buildDashboardOverview)).Methods("GET")
+	r.HandleFunc("/sites", dashboardHandler("sites", "Sites", buildDashboardSites)).Methods("GET")
+	r.HandleFunc("/site", dashboardHandler("site", "Site", buildDashboardSite)).Methods("GET")
+	r.HandleFunc("/libraries", dashboardHandler("libraries", "Libraries", buildDashboardLibraries)).Methods("GET")
+	r.HandleFunc("/versions", dashboardHandler("versions", "Versions by Site", buildDashboardVersions)).Methods("GET")
+	r.HandleFunc("/scans", dashboardHandler("scans", "Recent Scans", buildDashboardScans)).Methods("GET")
+	r.HandleFunc("/reachability", dashboardHandler("reachability", "Reachability", buildDashboardReachability)).Methods("GET")
+	return nil
+}
+
+// dashboardHandler renders a page from the data returned by build for the
+// scan run selected with ?run=id
+func dashboardHandler(page, title string, build func(r *http.Request, runID int64) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := dashboardPage{Title: title, Active: page, Query: r.URL.Query()}
+
+		if store == nil {
+			renderDashboardPage(w, http.StatusServiceUnavailable, "unavailable", data)
+			return
+		}
+
+		if value := data.Query.Get("run"); value != "" {
+			runID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || runID < 0 {
+				http.Error(w, fmt.Sprintf("invalid scan run ID %q", value), http.StatusBadRequest)
+				return
+			}
+			data.RunID = runID
+		}
+
+		var err error
+		if data.Runs, err = getScanRuns(dashboardRunLimit); err != nil {
+			logger.Printf("Error retrieving scan runs: %v\n", err)
+		}
+		if data.Data, err = build(r, data.RunID); err != nil {
+			logger.Printf("Error building dashboard page %s: %v\n", page, err)
+			http.Error(w, "error reading scan data", http.StatusInternalServerError)
+			return
+		}
+		renderDashboardPage(w, http.StatusOK, page, data)
+	}
+}
+
+// renderDashboardPage executes a page template into the response
+func renderDashboardPage(w http.ResponseWriter, status int, page string, data dashboardPage) {
+	var buf bytes.Buffer
+	if err := dashboardPages[page].ExecuteTemplate(&buf, "layout.html", data); err != nil {
+		logger.Printf("Error rendering dashboard page %s: %v\n", page, err)
+		http.Error(w, "error rendering page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// latestScripts keeps the most recent result of each script on each site,
+// so scanning a site in several runs does not count its scripts twice
+func latestScripts(results []ScanResult) []ScanResult {
+	index := make(map[string]int)
+	var latest []ScanResult
+	for _, r := range results {
+		key := r.URL + "\x00" + r.ScriptURL
+		if i, exists := index[key]; exists {
+			if !r.ScannedAt.Before(latest[i].ScannedAt) {
+				latest[i] = r
+			}
+			continue
+		}
+		index[key] = len(latest)
+		latest = append(latest, r)
+	}
+	return latest
+}
+
+// isIdentified reports whether a script was matched to a named library
+func isIdentified(r ScanResult) bool {
+	return r.LibraryName != "" && !strings.EqualFold(r.LibraryName, "unknown")
+}
+
+// containsFold reports whether substr is within s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// dashboardSites groups the latest scripts by site
+func dashboardSites(runID int64) ([]dashboardSite, error) {
+	results, err := getScanResults(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	bySite := make(map[string]*dashboardSite)
+	for _, r := range latestScripts(results) {
+		site, exists := bySite[r.URL]
+		if !exists {
+			site = &dashboardSite{URL: r.URL}
+			bySite[r.URL] = site
+		}
+		site.Scripts = append(site.Scripts, r)
+		if r.ScannedAt.After(site.LastScanned) {
+			site.LastScanned = r.ScannedAt
+		}
+		if r.Owner != "" || len(r.Tags) > 0 {
+			site.Owner, site.Tags = r.Owner, r.Tags
+		}
+		if len(r.Vulnerabilities) > 0 {
+			site.Vulnerable++
+		}
+		if isIdentified(r) {
+			name := r.LibraryName
+			if isComparableVersion(r.LibraryVersion) {
+				name += " " + r.LibraryVersion
+			}
+			site.Libraries = append(site.Libraries, name)
+		}
+	}
+
+	sites := make([]dashboardSite, 0, len(bySite))
+	for _, key := range sortedKeys(bySite) {
+		site := bySite[key]
+		sort.Strings(site.Libraries)
+		sites = append(sites, *site)
+	}
+	return sites, nil
+}
+
+func buildDashboardOverview(r *http.Request, runID int64) (interface{}, error) {
+	overview := &dashboardOverview{}
+	var err error
+	if overview.Stats, err = getOverallStatistics(runID); err != nil {
+		return nil, err
+	}
+	if overview.Reachability, err = getURLReachabilityStatistics(runID); err != nil {
+		return nil, err
+	}
+	if overview.Vulnerable, err = getVulnerabilityStatistics(runID); err != nil {
+		return nil, err
+	}
+	if overview.Batches, err = getNmapBatchStatistics(runID); err != nil {
+		return nil, err
+	}
+
+	sites, err := dashboardSites(runID)
+	if err != nil {
+		return nil, err
+	}
+	report := &reportData{}
+	for _, site := range sites {
+		scripts := make([]scriptRecord, 0, len(site.Scripts))
+		for _, s := range site.Scripts {
+			scripts = append(scripts, newScriptRecord(s))
+		}
+		report.Sites = append(report.Sites, reportSite{URL: site.URL, Scripts: scripts})
+	}
+	report.summarize()
+	overview.Libraries = report.Libraries
+	overview.Sites = len(sites)
+	return overview, nil
+}
+
+// buildDashboardSites lists sites, filtered by URL (?q=) and by the
+// libraries they load (?library=)
+func buildDashboardSites(r *http.Request, runID int64) (interface{}, error) {
+	sites, err := dashboardSites(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	library := strings.TrimSpace(r.URL.Query().Get("library"))
+	owner := strings.TrimSpace(r.URL.Query().Get("owner"))
+	vulnerableOnly := r.URL.Query().Get("vulnerable") != ""
+
+	var filtered []dashboardSite
+	for _, site := range sites {
+		if query != "" && !containsFold(site.URL, query) && !containsFold(strings.Join(site.Tags, " "), query) {
+			continue
+		}
+		if owner != "" && !strings.EqualFold(site.Owner, owner) {
+			continue
+		}
+		if vulnerableOnly && site.Vulnerable == 0 {
+			continue
+		}
+		if library != "" {
+			found := false
+			for _, s := range site.Scripts {
+				if strings.EqualFold(s.LibraryName, library) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		filtered = append(filtered, site)
+	}
+	return filtered, nil
+}
+
+// buildDashboardSite shows the scripts of the site given with ?url=
+func buildDashboardSite(r *http.Request, runID int64) (interface{}, error) {
+	sites, err := dashboardSites(runID)
+	if err != nil {
+		return nil, err
+	}
+	siteURL := r.URL.Query().Get("url")
+	for _, site := range sites {
+		if site.URL == siteURL {
+			return &site, nil
+		}
+	}
+	return nil, nil
+}
+
+// buildDashboardLibraries lists identified libraries, filtered by name
+// (?q=) and optionally to vulnerable ones (?vulnerable=1)
+func buildDashboardLibraries(r *http.Request, runID int64) (interface{}, error) {
+	sites, err := dashboardSites(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	type usage struct {
+		versions   map[string]bool
+		sites      map[string]bool
+		vulnerable map[string]bool
+	}
+	byName := make(map[string]*usage)
+	for _, site := range sites {
+		for _, s := range site.Scripts {
+			if !isIdentified(s) {
+				continue
+			}
+			u, exists := byName[s.LibraryName]
+			if !exists {
+				u = &usage{versions: map[string]bool{}, sites: map[string]bool{}, vulnerable: map[string]bool{}}
+				byName[s.LibraryName] = u
+			}
+			if isComparableVersion(s.LibraryVersion) {
+				u.versions[s.LibraryVersion] = true
+			}
+			u.sites[site.URL] = true
+			if len(s.Vulnerabilities) > 0 {
+				u.vulnerable[site.URL] = true
+			}
+		}
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	vulnerableOnly := r.URL.Query().Get("vulnerable") != ""
+
+	var libraries []dashboardLibrary
+	for name, u := range byName {
+		if query != "" && !containsFold(name, query) {
+			continue
+		}
+		if vulnerableOnly && len(u.vulnerable) == 0 {
+			continue
+		}
+		versions := sortedKeys(u.versions)
+		sort.SliceStable(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) > 0 })
+		libraries = append(libraries, dashboardLibrary{
+			Name:       name,
+			Versions:   versions,
+			Sites:      len(u.sites),
+			Vulnerable: len(u.vulnerable),
+		})
+	}
+	sort.Slice(libraries, func(i, j int) bool {
+		if libraries[i].Sites != libraries[j].Sites {
+			return libraries[i].Sites > libraries[j].Sites
+		}
+		return libraries[i].Name < libraries[j].Name
+	})
+	return libraries, nil
+}
+
+// buildDashboardVersions lists the versions of each library with the sites
+// using them, limited to one library (?library=) or a name search (?q=)
+func buildDashboardVersions(r *http.Request, runID int64) (interface{}, error) {
+	sites, err := dashboardSites(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	library := strings.TrimSpace(r.URL.Query().Get("library"))
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	type usage struct {
+		version   *dashboardVersion
+		sites     map[string]bool
+		checksums map[string]bool
+	}
+	byVersion := make(map[string]*usage)
+	for _, site := range sites {
+		for _, s := range site.Scripts {
+			if !isIdentified(s) {
+				continue
+			}
+			if library != "" && !strings.EqualFold(s.LibraryName, library) {
+				continue
+			}
+			if query != "" && !containsFold(s.LibraryName, query) && !containsFold(site.URL, query) {
+				continue
+			}
+
+			version := s.LibraryVersion
+			if !isComparableVersion(version) {
+				version = "unknown"
+			}
+			key := s.LibraryName + "\x00" + version
+			u, exists := byVersion[key]
+			if !exists {
+				u = &usage{
+					version:   &dashboardVersion{Library: s.LibraryName, Version: version},
+					sites:     map[string]bool{},
+					checksums: map[string]bool{},
+				}
+				byVersion[key] = u
+			}
+			u.sites[site.URL] = true
+			u.checksums[s.Checksum] = true
+			if len(s.Vulnerabilities) > 0 && len(u.version.Vulnerabilities) == 0 {
+				u.version.Vulnerabilities = s.Vulnerabilities
+			}
+		}
+	}
+
+	versions := make([]dashboardVersion, 0, len(byVersion))
+	for _, u := range byVersion {
+		u.version.Sites = sortedKeys(u.sites)
+		u.version.Checksums = len(u.checksums)
+		versions = append(versions, *u.version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Library != versions[j].Library {
+			return versions[i].Library < versions[j].Library
+		}
+		return compareVersions(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// buildDashboardScans lists the recorded scan runs and the most recently
+// scanned sites
+func buildDashboardScans(r *http.Request, runID int64) (interface{}, error) {
+	recent, err := getRecentScans(dashboardRunLimit, runID)
+	if err != nil {
+		return nil, err
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var filtered []RecentScan
+	for _, scan := range recent {
+		if query == "" || containsFold(scan.URL, query) {
+			filtered = append(filtered, scan)
+		}
+	}
+	return filtered, nil
+}
+
+// buildDashboardReachability shows the reachability breakdown and the
+// latest check of each URL, filtered by state (?state=) and URL (?q=)
+func buildDashboardReachability(r *http.Request, runID int64) (interface{}, error) {
+	data := &dashboardReachability{State: r.URL.Query().Get("state")}
+	var err error
+	if data.Reachability, err = getURLReachabilityStatistics(runID); err != nil {
+		return nil, err
+	}
+	checks, err := store.URLReachabilityChecks(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	seen := make(map[string]bool)
+	for _, check := range checks {
+		if seen[check.OriginalURL] {
+			continue
+		}
+		seen[check.OriginalURL] = true
+
+		if query != "" && !containsFold(check.OriginalURL, query) && !containsFold(check.FinalURL, query) {
+			continue
+		}
+		if !matchesReachabilityState(check, data.State) {
+			continue
+		}
+		data.Checks = append(data.Checks, check)
+	}
+	return data, nil
+}
+
+// matchesReachabilityState reports whether a check falls into the given
+// reachability filter; an empty state matches every check
+func matchesReachabilityState(check URLReachability, state string) bool {
+	switch state {
+	case reachBoth:
+		return check.HTTPAvailable && check.HTTPSAvailable
+	case reachHTTPSOnly:
+		return check.HTTPSAvailable && !check.HTTPAvailable
+	case reachHTTPOnly:
+		return check.HTTPAvailable && !check.HTTPSAvailable
+	case reachUnreachable:
+		return !check.HTTPAvailable && !check.HTTPSAvailable
+	case reachRedirect:
+		return check.HTTPRedirectURL != "" || check.HTTPSRedirectURL != ""
+	}
+	return true
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
 	"time"
-
-	_ "github.com/go-sql-driver/mysql"
 )
 
-var db *sql.DB
-
 // ScanResult holds the result of a single script scan.
 type ScanResult struct {
 	URL              string
@@ -17,117 +14,144 @@ type ScanResult struct {
 	LibraryName      string
 	LibraryVersion   string
 	IdentifiedBy     string // Method used for identification (url-pattern, api, code-analysis, etc.)
+	IsInline         bool   // Script body was embedded in the page; ScriptURL is a synthetic inline:#N
+	Vulnerabilities  []Vulnerability
+	RunID            int64 // Scan run that produced the result, 0 if not recorded
+	RootURL          string   // Site the page belongs to: the scanned input URL, or the URL a page was discovered from
+	Owner            string   // Owning team of the scanned site, from the input list
+	Tags             []string // Tags of the scanned site, from the input list
 	ScannedAt        time.Time
 }
 
-// initDB initializes the database connection.
-func initDB(dataSourceName string) error {
-	var err error
-	db, err = sql.Open("mysql", dataSourceName)
-	if err != nil {
-		return err
-	}
-	return db.Ping()
+// ScanRun describes one invocation of the scanner
+type ScanRun struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	InputFile  string
+	Flags      string // Command line flags that were set explicitly
+	Workers    int
+	TotalURLs  int64
+	Processed  int64
+	Scanned    int64
+	Excluded   int64
+	Skipped    int64
+	Errors     int64
 }
 
-// createTable creates the necessary table in the database if it doesn't exist.
-func createTable() error {
-	// Create scan_results table
-	query := `
-	CREATE TABLE IF NOT EXISTS scan_results (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		url VARCHAR(2083) NOT NULL,
-		script_url VARCHAR(2083) NOT NULL,
-		checksum VARCHAR(64) NOT NULL,
-		library_name VARCHAR(255),
-		library_version VARCHAR(100),
-		identified_by VARCHAR(50),
-		scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		date DATE,
-		INDEX idx_library (library_name),
-		INDEX idx_checksum (checksum)
-	);`
-	if _, err := db.Exec(query); err != nil {
-		return err
-	}
+// Store is the storage backend for scan results, reachability data, port scan
+// batches and the statistics derived from them
+type Store interface {
+	// CreateSchema brings the schema up to date by running pending migrations
+	CreateSchema() error
+	// Migrate applies pending migrations and returns how many were applied
+	Migrate() (int, error)
+	MigrationStatus() ([]MigrationStatus, error)
+	StoreResult(result ScanResult) error
+	StoreURLReachability(result *URLReachability) error
+	// StoreResults and StoreURLReachabilities store many rows in one
+	// transaction; either all rows are stored or none
+	StoreResults(results []ScanResult) error
+	StoreURLReachabilities(results []*URLReachability) error
+	StoreBatchID(batchID, url string, runID int64) error
+	// UpdateBatchStatus records the outcome of a batch and its raw nmap XML
+	UpdateBatchStatus(batchID, status, results string) error
+	// StoreNmapResults stores the hosts and open ports a batch found for a URL
+	StoreNmapResults(batchID, url string, runID int64, results []NmapResult) error
+	// StartScanRun records a new scan run and assigns its ID
+	StartScanRun(run *ScanRun) error
+	// FinishScanRun stores the end time and counters of a scan run
+	FinishScanRun(run *ScanRun) error
+	ScanRuns(limit int) ([]ScanRun, error)
+	ScanRun(id int64) (*ScanRun, error)
+	// ScanResults returns the stored scan results of a run, or of all runs for runID 0
+	ScanResults(runID int64) ([]ScanResult, error)
+	// NmapBatches returns the port scan batches of a run, or of all runs for runID 0
+	NmapBatches(runID int64) ([]NmapBatch, error)
+	// URLReachabilityChecks returns the reachability checks of a run, or of all runs for runID 0, newest first
+	URLReachabilityChecks(runID int64) ([]URLReachability, error)
+	// LookupChecksum returns a previously identified library with the given checksum
+	LookupChecksum(ctx context.Context, checksum string) (*LibraryInfo, error)
+
+	// The statistics queries cover a single scan run, or all runs for runID 0
+	OverallStatistics(runID int64) (*Statistics, error)
+	LibraryStatistics(runID int64) ([]LibraryUsage, error)
+	VulnerabilityStatistics(runID int64) ([]VulnerableLibrary, error)
+	RecentScans(limit int, runID int64) ([]RecentScan, error)
+	NmapBatchStatistics(runID int64) (map[string]int, error)
+	PortStatistics(runID int64) (*PortScanStats, error)
+	URLReachabilityStatistics(runID int64) (*URLReachabilityStats, error)
+
+	Close() error
+}
 
-	// Create nmap_batches table for tracking port scan batches
-	nmapQuery := `
-	CREATE TABLE IF NOT EXISTS nmap_batches (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		batch_id VARCHAR(255) NOT NULL UNIQUE,
-		url VARCHAR(2083) NOT NULL,
-		status VARCHAR(50) NOT NULL,
-		ports TEXT,
-		results TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-		INDEX idx_batch_id (batch_id),
-		INDEX idx_status (status)
-	);`
-	if _, err := db.Exec(nmapQuery); err != nil {
-		return err
+// store is the active storage backend, nil when database storage is disabled
+var store Store
+
+// DBConfig holds the database settings gathered from flags and environment
+type DBConfig struct {
+	Driver   string // mysql, postgres or sqlite
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	Path     string // Database file for sqlite
+	SSLMode  string // Connection SSL mode for postgres
+}
+
+// defaultDBPort returns the standard server port for a database driver
+func defaultDBPort(driver string) string {
+	if driver == "postgres" {
+		return "5432"
 	}
+	return "3306"
+}
 
-	// Create url_reachability table
-	reachabilityQuery := `
-	CREATE TABLE IF NOT EXISTS url_reachability (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		original_url VARCHAR(2083) NOT NULL,
-		http_available BOOLEAN DEFAULT FALSE,
-		https_available BOOLEAN DEFAULT FALSE,
-		http_status_code INT,
-		https_status_code INT,
-		http_redirect_url VARCHAR(2083),
-		https_redirect_url VARCHAR(2083),
-		final_url VARCHAR(2083),
-		scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		INDEX idx_original_url (original_url),
-		INDEX idx_scanned_at (scanned_at),
-		INDEX idx_availability (http_available, https_available)
-	);`
-	_, err := db.Exec(reachabilityQuery)
+// initDB initializes the database connection.
+func initDB(config DBConfig) error {
+	var err error
+	switch config.Driver {
+	case "mysql", "":
+		store, err = newMySQLStore(config)
+	case "postgres":
+		store, err = newPostgresStore(config)
+	case "sqlite":
+		store, err = newSQLiteStore(config)
+	default:
+		return fmt.Errorf("unsupported database driver %q", config.Driver)
+	}
 	return err
 }
 
+// createTable creates or upgrades the database tables by running pending migrations.
+func createTable() error {
+	return store.CreateSchema()
+}
+
 // storeResult stores a scan result in the database.
 func storeResult(result ScanResult) error {
-	query := "INSERT INTO scan_results (url, script_url, checksum, library_name, library_version, identified_by, date) VALUES (?, ?, ?, ?, ?, ?, ?)"
-	_, err := db.Exec(query, result.URL, result.ScriptURL, result.Checksum, result.LibraryName, result.LibraryVersion, result.IdentifiedBy, time.Now().Format("2006-01-02"))
-	return err
+	return store.StoreResult(result)
+}
+
+// getScanResults retrieves stored scan results of a run, or of all runs for runID 0
+func getScanResults(runID int64) ([]ScanResult, error) {
+	return store.ScanResults(runID)
 }
 
 // storeURLReachability stores URL reachability information in the database
 func storeURLReachability(result *URLReachability) error {
-	query := `INSERT INTO url_reachability 
-		(original_url, http_available, https_available, http_status_code, https_status_code, 
-		 http_redirect_url, https_redirect_url, final_url) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	
-	// Convert empty strings to NULL for database storage
-	var httpRedirect, httpsRedirect, finalURL interface{}
-	if result.HTTPRedirectURL != "" {
-		httpRedirect = result.HTTPRedirectURL
-	}
-	if result.HTTPSRedirectURL != "" {
-		httpsRedirect = result.HTTPSRedirectURL
-	}
-	if result.FinalURL != "" {
-		finalURL = result.FinalURL
-	}
-	
-	// Convert zero status codes to NULL
-	var httpStatus, httpsStatus interface{}
-	if result.HTTPStatusCode > 0 {
-		httpStatus = result.HTTPStatusCode
-	}
-	if result.HTTPSStatusCode > 0 {
-		httpsStatus = result.HTTPSStatusCode
-	}
-	
-	_, err := db.Exec(query, result.OriginalURL, result.HTTPAvailable, result.HTTPSAvailable, 
-		httpStatus, httpsStatus, httpRedirect, httpsRedirect, finalURL)
-	return err
+	return store.StoreURLReachability(result)
+}
+
+// startScanRun records the start of a scan run
+func startScanRun(run *ScanRun) error {
+	return store.StartScanRun(run)
+}
+
+// finishScanRun records the end time and counters of a scan run
+func finishScanRun(run *ScanRun) error {
+	return store.FinishScanRun(run)
 }
 
 // Statistics represents overall scan statistics
@@ -148,137 +172,52 @@ type LibraryUsage struct {
 	IdentifiedBy string
 }
 
+// VulnerableLibrary represents a library version with known advisories
+type VulnerableLibrary struct {
+	Name     string
+	Version  string
+	CVEIDs   string
+	Severity string
+	FixedIn  string
+	Sites    int
+}
+
 // RecentScan represents a recent scan entry
 type RecentScan struct {
 	URL       string
 	ScannedAt time.Time
 }
 
-// getOverallStatistics retrieves overall statistics from the database
-func getOverallStatistics() (*Statistics, error) {
-	stats := &Statistics{}
-	
-	// Get total unique URLs
-	err := db.QueryRow("SELECT COUNT(DISTINCT url) FROM scan_results").Scan(&stats.TotalURLs)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get total scripts
-	err = db.QueryRow("SELECT COUNT(*) FROM scan_results").Scan(&stats.TotalScripts)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get unique libraries (excluding Unknown and empty)
-	err = db.QueryRow("SELECT COUNT(DISTINCT library_name) FROM scan_results WHERE library_name IS NOT NULL AND library_name != '' AND library_name != 'Unknown'").Scan(&stats.UniqueLibraries)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get first and last scan times
-	var firstScan, lastScan sql.NullTime
-	err = db.QueryRow("SELECT MIN(scanned_at), MAX(scanned_at) FROM scan_results").Scan(&firstScan, &lastScan)
-	if err != nil {
-		return nil, err
-	}
-	
-	if firstScan.Valid {
-		stats.FirstScan = &firstScan.Time
-	}
-	if lastScan.Valid {
-		stats.LastScan = &lastScan.Time
-	}
-	
-	return stats, nil
+// NmapBatch represents a stored port scan batch
+type NmapBatch struct {
+	BatchID   string
+	URL       string
+	Status    string
+	Results   string // Raw nmap XML, empty until the batch results are stored
+	RunID     int64
+	CreatedAt time.Time
 }
 
-// getLibraryStatistics retrieves library usage statistics
-func getLibraryStatistics() ([]LibraryUsage, error) {
-	query := `
-		SELECT 
-			library_name, 
-			COALESCE(library_version, '') as library_version,
-			checksum,
-			COUNT(*) as count,
-			MAX(identified_by) as identified_by
-		FROM scan_results 
-		WHERE library_name IS NOT NULL AND library_name != '' 
-		GROUP BY library_name, library_version, checksum 
-		ORDER BY count DESC, library_name ASC, library_version ASC
-	`
-	
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var libraries []LibraryUsage
-	for rows.Next() {
-		var lib LibraryUsage
-		if err := rows.Scan(&lib.Name, &lib.Version, &lib.Checksum, &lib.Count, &lib.IdentifiedBy); err != nil {
-			return nil, err
-		}
-		libraries = append(libraries, lib)
-	}
-	
-	return libraries, rows.Err()
-}
+// Outcomes of an nmap batch as stored in nmap_batches.status
+const (
+	BatchRunning   = "running"
+	BatchCompleted = "completed"
+	BatchFailed    = "failed"
+)
 
-// getRecentScans retrieves the most recent scans
-func getRecentScans(limit int) ([]RecentScan, error) {
-	query := `
-		SELECT DISTINCT url, MAX(scanned_at) as last_scan 
-		FROM scan_results 
-		GROUP BY url 
-		ORDER BY last_scan DESC 
-		LIMIT ?
-	`
-	
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var scans []RecentScan
-	for rows.Next() {
-		var scan RecentScan
-		if err := rows.Scan(&scan.URL, &scan.ScannedAt); err != nil {
-			return nil, err
-		}
-		scans = append(scans, scan)
-	}
-	
-	return scans, rows.Err()
+// PortScanStats represents statistics about the stored port scan results
+type PortScanStats struct {
+	HostsScanned       int
+	HostsWithOpenPorts int
+	Ports              []OpenPortUsage
 }
 
-// getNmapBatchStatistics retrieves nmap batch statistics
-func getNmapBatchStatistics() (map[string]int, error) {
-	query := `
-		SELECT status, COUNT(*) as count 
-		FROM nmap_batches 
-		GROUP BY status
-	`
-	
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	stats := make(map[string]int)
-	for rows.Next() {
-		var status string
-		var count int
-		if err := rows.Scan(&status, &count); err != nil {
-			return nil, err
-		}
-		stats[status] = count
-	}
-	
-	return stats, rows.Err()
+// OpenPortUsage represents how many hosts have a port open
+type OpenPortUsage struct {
+	Port     int
+	Protocol string
+	Service  string // Service name nmap reported for the port
+	Hosts    int
 }
 
 // URLReachabilityStats represents statistics about URL reachability
@@ -291,45 +230,42 @@ type URLReachabilityStats struct {
 	RedirectCount      int
 }
 
+// getScanRuns retrieves the most recent scan runs
+func getScanRuns(limit int) ([]ScanRun, error) {
+	return store.ScanRuns(limit)
+}
+
+// getOverallStatistics retrieves overall statistics from the database
+func getOverallStatistics(runID int64) (*Statistics, error) {
+	return store.OverallStatistics(runID)
+}
+
+// getLibraryStatistics retrieves library usage statistics
+func getLibraryStatistics(runID int64) ([]LibraryUsage, error) {
+	return store.LibraryStatistics(runID)
+}
+
+// getVulnerabilityStatistics retrieves library versions with known advisories
+func getVulnerabilityStatistics(runID int64) ([]VulnerableLibrary, error) {
+	return store.VulnerabilityStatistics(runID)
+}
+
+// getRecentScans retrieves the most recent scans
+func getRecentScans(limit int, runID int64) ([]RecentScan, error) {
+	return store.RecentScans(limit, runID)
+}
+
+// getNmapBatchStatistics retrieves nmap batch statistics
+func getNmapBatchStatistics(runID int64) (map[string]int, error) {
+	return store.NmapBatchStatistics(runID)
+}
+
+// getPortStatistics retrieves open port statistics
+func getPortStatistics(runID int64) (*PortScanStats, error) {
+	return store.PortStatistics(runID)
+}
+
 // getURLReachabilityStatistics retrieves URL reachability statistics
-func getURLReachabilityStatistics() (*URLReachabilityStats, error) {
-	stats := &URLReachabilityStats{}
-	
-	// Get total checked URLs
-	err := db.QueryRow("SELECT COUNT(*) FROM url_reachability").Scan(&stats.TotalChecked)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get HTTP only count
-	err = db.QueryRow("SELECT COUNT(*) FROM url_reachability WHERE http_available = TRUE AND https_available = FALSE").Scan(&stats.HTTPOnlyCount)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get HTTPS only count
-	err = db.QueryRow("SELECT COUNT(*) FROM url_reachability WHERE http_available = FALSE AND https_available = TRUE").Scan(&stats.HTTPSOnlyCount)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get both protocols count
-	err = db.QueryRow("SELECT COUNT(*) FROM url_reachability WHERE http_available = TRUE AND https_available = TRUE").Scan(&stats.BothProtocolsCount)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get unreachable count
-	err = db.QueryRow("SELECT COUNT(*) FROM url_reachability WHERE http_available = FALSE AND https_available = FALSE").Scan(&stats.UnreachableCount)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Get redirect count
-	err = db.QueryRow("SELECT COUNT(*) FROM url_reachability WHERE http_redirect_url IS NOT NULL OR https_redirect_url IS NOT NULL").Scan(&stats.RedirectCount)
-	if err != nil {
-		return nil, err
-	}
-	
-	return stats, nil
+func getURLReachabilityStatistics(runID int64) (*URLReachabilityStats, error) {
+	return store.URLReachabilityStatistics(runID)
 }
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of changes reported by a run diff
+const (
+	ChangeScriptAdded     = "added"
+	ChangeScriptRemoved   = "removed"
+	ChangeUpgraded        = "upgraded"
+	ChangeDowngraded      = "downgraded"
+	ChangeVersionChanged  = "version-changed"  // Library changed or versions cannot be compared
+	ChangeChecksumChanged = "checksum-changed" // Same library version, different content
+)
+
+// ScriptSnapshot is the state of a script URL in one scan run
+type ScriptSnapshot struct {
+	LibraryName    string `json:"library_name"`
+	LibraryVersion string `json:"library_version,omitempty"`
+	Checksum       string `json:"checksum"`
+}
+
+// ScriptChange describes how a single script of a site changed between runs.
+// A library whose script URL changed (a CDN version bump) is reported as one
+// change with the old URL in PreviousURL.
+type ScriptChange struct {
+	Kind        string          `json:"kind"`
+	ScriptURL   string          `json:"script_url"`
+	PreviousURL string          `json:"previous_script_url,omitempty"`
+	Before      *ScriptSnapshot `json:"before,omitempty"`
+	After       *ScriptSnapshot `json:"after,omitempty"`
+}
+
+// SiteDiff lists the script changes of a site scanned in both runs
+type SiteDiff struct {
+	URL     string         `json:"url"`
+	Changes []ScriptChange `json:"changes"`
+}
+
+// RunDiff is the comparison of two scan runs
+type RunDiff struct {
+	RunA             int64      `json:"run_a"`
+	RunB             int64      `json:"run_b"`
+	Sites            []SiteDiff `json:"sites"`
+	SitesAdded       []string   `json:"sites_added"`       // Scanned in run B only
+	SitesRemoved     []string   `json:"sites_removed"`     // Scanned in run A only
+	SitesUnreachable []string   `json:"sites_unreachable"` // Scanned in run A, unreachable in run B
+	SitesRecovered   []string   `json:"sites_recovered"`   // Unreachable in run A, scanned in run B
+	Unchanged        int        `json:"unchanged_sites"`
+}
+
+// runSites is what a run found: the scripts of every site it scanned, also
+// of sites without scripts, and the input URLs it could not reach
+type runSites struct {
+	scripts     map[string]map[string]ScriptSnapshot
+	scannedURL  map[string]string // Scanned URL by input URL
+	unreachable []string          // Input URLs without an HTTP 200 response
+}
+
+// newRunSites combines the scan results of a run with its reachability
+// checks. Without checks, as for runs stored before they were recorded,
+// only sites with scripts count as scanned.
+func newRunSites(results []ScanResult, checks []URLReachability) *runSites {
+	run := &runSites{scripts: groupScriptsBySite(results), scannedURL: make(map[string]string)}
+	for _, check := range checks {
+		if !check.HasSuccessfulResponse() {
+			run.unreachable = append(run.unreachable, check.OriginalURL)
+			continue
+		}
+		scanned := check.FinalURL
+		if scanned == "" {
+			scanned = check.OriginalURL
+		}
+		run.scannedURL[check.OriginalURL] = scanned
+		if _, exists := run.scripts[scanned]; !exists {
+			run.scripts[scanned] = make(map[string]ScriptSnapshot)
+		}
+	}
+	return run
+}
+
+// unreachableSites returns the sites of other that this run could not
+// reach, identified by the URL other scanned them under
+func (run *runSites) unreachableSites(other *runSites) map[string]bool {
+	sites := make(map[string]bool)
+	for _, inputURL := range run.unreachable {
+		site, exists := other.scannedURL[inputURL]
+		if !exists {
+			site = inputURL
+		}
+		if _, scanned := other.scripts[site]; scanned {
+			sites[site] = true
+		}
+	}
+	return sites
+}
+
+// groupScriptsBySite indexes scan results by base URL and script URL.
+// Inline scripts are keyed by their checksum instead of their position, so
+// adding a script block to a page does not renumber the others; a changed
+// inline script shows up as removed and added.
+func groupScriptsBySite(results []ScanResult) map[string]map[string]ScriptSnapshot {
+	sites := make(map[string]map[string]ScriptSnapshot)
+	for _, r := range results {
+		scripts, exists := sites[r.URL]
+		if !exists {
+			scripts = make(map[string]ScriptSnapshot)
+			sites[r.URL] = scripts
+		}
+		key := r.ScriptURL
+		if r.IsInline {
+			key = inlineScriptKey(r.Checksum)
+		}
+		scripts[key] = ScriptSnapshot{
+			LibraryName:    r.LibraryName,
+			LibraryVersion: r.LibraryVersion,
+			Checksum:       r.Checksum,
+		}
+	}
+	return sites
+}
+
+// inlineScriptKey identifies an inline script by its content
+func inlineScriptKey(checksum string) string {
+	if len(checksum) > 16 {
+		checksum = checksum[:16]
+	}
+	return "inline:" + checksum
+}
+
+// diffScanResults compares the scan results of two runs site by site. The
+// reachability checks of the runs tell a site that lost all its scripts from
+// one that was not scanned, and sites one run could not reach are listed
+// apart from those only the other run scanned.
+func diffScanResults(runA, runB int64, resultsA, resultsB []ScanResult, checksA, checksB []URLReachability) *RunDiff {
+	diff := &RunDiff{RunA: runA, RunB: runB, Sites: []SiteDiff{}, SitesAdded: []string{}, SitesRemoved: []string{},
+		SitesUnreachable: []string{}, SitesRecovered: []string{}}
+	runSitesA := newRunSites(resultsA, checksA)
+	runSitesB := newRunSites(resultsB, checksB)
+	sitesA, sitesB := runSitesA.scripts, runSitesB.scripts
+	unreachableB := runSitesB.unreachableSites(runSitesA)
+	unreachableA := runSitesA.unreachableSites(runSitesB)
+
+	for _, site := range sortedKeys(sitesA) {
+		if _, exists := sitesB[site]; exists {
+			continue
+		}
+		if unreachableB[site] {
+			diff.SitesUnreachable = append(diff.SitesUnreachable, site)
+		} else {
+			diff.SitesRemoved = append(diff.SitesRemoved, site)
+		}
+	}
+
+	for _, site := range sortedKeys(sitesB) {
+		before, exists := sitesA[site]
+		if !exists {
+			if unreachableA[site] {
+				diff.SitesRecovered = append(diff.SitesRecovered, site)
+			} else {
+				diff.SitesAdded = append(diff.SitesAdded, site)
+			}
+			continue
+		}
+		changes := diffSiteScripts(before, sitesB[site])
+		if len(changes) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Sites = append(diff.Sites, SiteDiff{URL: site, Changes: changes})
+	}
+	return diff
+}
+
+// diffSiteScripts compares the scripts of one site between two runs
+func diffSiteScripts(before, after map[string]ScriptSnapshot) []ScriptChange {
+	var changes []ScriptChange
+	var removed, added []ScriptChange
+
+	for _, scriptURL := range sortedKeys(before) {
+		old := before[scriptURL]
+		current, exists := after[scriptURL]
+		switch {
+		case !exists:
+			removed = append(removed, ScriptChange{Kind: ChangeScriptRemoved, ScriptURL: scriptURL, Before: &old})
+		case old.LibraryName != current.LibraryName || old.LibraryVersion != current.LibraryVersion:
+			changes = append(changes, ScriptChange{Kind: versionChangeKind(old, current), ScriptURL: scriptURL, Before: &old, After: &current})
+		case old.Checksum != current.Checksum:
+			changes = append(changes, ScriptChange{Kind: ChangeChecksumChanged, ScriptURL: scriptURL, Before: &old, After: &current})
+		}
+	}
+
+	for _, scriptURL := range sortedKeys(after) {
+		if _, exists := before[scriptURL]; !exists {
+			current := after[scriptURL]
+			added = append(added, ScriptChange{Kind: ChangeScriptAdded, ScriptURL: scriptURL, After: &current})
+		}
+	}
+
+	changes = append(changes, pairMovedScripts(removed, added)...)
+	return changes
+}
+
+// pairMovedScripts turns a removed and an added script of the same library
+// into a single version change, so that a library loaded from a new URL
+// after an upgrade is not reported as removed and added. Scripts are paired
+// in URL order; those without a counterpart stay removed or added.
+func pairMovedScripts(removed, added []ScriptChange) []ScriptChange {
+	var changes []ScriptChange
+	paired := make([]bool, len(added))
+
+	for _, r := range removed {
+		match := -1
+		if isIdentifiedSnapshot(r.Before) {
+			for i, a := range added {
+				if !paired[i] && a.After.LibraryName == r.Before.LibraryName && a.After.LibraryVersion != r.Before.LibraryVersion {
+					match = i
+					break
+				}
+			}
+		}
+		if match < 0 {
+			changes = append(changes, r)
+			continue
+		}
+		paired[match] = true
+		a := added[match]
+		changes = append(changes, ScriptChange{
+			Kind:        versionChangeKind(*r.Before, *a.After),
+			ScriptURL:   a.ScriptURL,
+			PreviousURL: r.ScriptURL,
+			Before:      r.Before,
+			After:       a.After,
+		})
+	}
+
+	for i, a := range added {
+		if !paired[i] {
+			changes = append(changes, a)
+		}
+	}
+	return changes
+}
+
+// versionChangeKind classifies the change between two states of a script as
+// an upgrade or downgrade, or as version-changed if the library changed or
+// either version cannot be compared
+func versionChangeKind(before, after ScriptSnapshot) string {
+	if before.LibraryName != after.LibraryName ||
+		!isComparableVersion(before.LibraryVersion) || !isComparableVersion(after.LibraryVersion) {
+		return ChangeVersionChanged
+	}
+	switch compareVersions(before.LibraryVersion, after.LibraryVersion) {
+	case -1:
+		return ChangeUpgraded
+	case 1:
+		return ChangeDowngraded
+	}
+	return ChangeVersionChanged
+}
+
+// isIdentifiedSnapshot reports whether a script state names a library
+func isIdentifiedSnapshot(s *ScriptSnapshot) bool {
+	return s.LibraryName != "" && !strings.EqualFold(s.LibraryName, "unknown")
+}
+
+// sortedKeys returns the keys of a map in lexical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describeSnapshot renders a script state as "jquery v3.7.1 [abcdef12...]"
+func describeSnapshot(s *ScriptSnapshot) string {
+	name := s.LibraryName
+	if s.LibraryVersion != "" && s.LibraryVersion != "unknown" {
+		name += " v" + s.LibraryVersion
+	}
+	checksum := s.Checksum
+	if len(checksum) > 8 {
+		checksum = checksum[:8] + "..."
+	}
+	return fmt.Sprintf("%s [%s]", name, checksum)
+}
+
+// changeMarker returns the symbol printed before a version change
+func changeMarker(kind string) string {
+	switch kind {
+	case ChangeUpgraded:
+		return "^"
+	case ChangeDowngraded:
+		return "v"
+	}
+	return "~"
+}
+
+// writeRunDiffText renders a run diff for the terminal
+func writeRunDiffText(w io.Writer, diff *RunDiff) {
+	fmt.Fprintf(w, "\n=== Changes from run %d to run %d ===\n", diff.RunA, diff.RunB)
+
+	if len(diff.Sites) == 0 {
+		fmt.Fprintln(w, "\nNo library changes on sites scanned in both runs.")
+	}
+	for _, site := range diff.Sites {
+		fmt.Fprintf(w, "\n%s\n", site.URL)
+		for _, change := range site.Changes {
+			switch change.Kind {
+			case ChangeScriptAdded:
+				fmt.Fprintf(w, "  + %s: %s\n", change.ScriptURL, describeSnapshot(change.After))
+			case ChangeScriptRemoved:
+				fmt.Fprintf(w, "  - %s: %s\n", change.ScriptURL, describeSnapshot(change.Before))
+			case ChangeUpgraded, ChangeDowngraded, ChangeVersionChanged:
+				fmt.Fprintf(w, "  %s %s: %s -> %s\n", changeMarker(change.Kind), change.ScriptURL, describeSnapshot(change.Before), describeSnapshot(change.After))
+				if change.PreviousURL != "" {
+					fmt.Fprintf(w, "      (was %s)\n", change.PreviousURL)
+				}
+			case ChangeChecksumChanged:
+				fmt.Fprintf(w, "  ! %s: content changed without version change %s -> %s\n",
+					change.ScriptURL, describeSnapshot(change.Before), describeSnapshot(change.After))
+			}
+		}
+	}
+
+	if len(diff.SitesAdded) > 0 {
+		fmt.Fprintf(w, "\nSites only in run %d:\n", diff.RunB)
+		for _, site := range diff.SitesAdded {
+			fmt.Fprintf(w, "  + %s\n", site)
+		}
+	}
+	if len(diff.SitesRemoved) > 0 {
+		fmt.Fprintf(w, "\nSites only in run %d:\n", diff.RunA)
+		for _, site := range diff.SitesRemoved {
+			fmt.Fprintf(w, "  - %s\n", site)
+		}
+	}
+	if len(diff.SitesUnreachable) > 0 {
+		fmt.Fprintf(w, "\nSites unreachable in run %d:\n", diff.RunB)
+		for _, site := range diff.SitesUnreachable {
+			fmt.Fprintf(w, "  ? %s\n", site)
+		}
+	}
+	if len(diff.SitesRecovered) > 0 {
+		fmt.Fprintf(w, "\nSites unreachable in run %d, scanned again in run %d:\n", diff.RunA, diff.RunB)
+		for _, site := range diff.SitesRecovered {
+			fmt.Fprintf(w, "  ? %s\n", site)
+		}
+	}
+
+	fmt.Fprintf(w, "\nChanged sites: %d, unchanged: %d, added: %d, removed: %d, unreachable: %d\n",
+		len(diff.Sites), diff.Unchanged, len(diff.SitesAdded), len(diff.SitesRemoved), len(diff.SitesUnreachable))
+}
+
+// runDiffCommand implements "netweather diff <runA> <runB>" and returns the
+// process exit code
+func runDiffCommand(args []string, format string, out io.Writer) int {
+	if len(args) != 2 {
+		fmt.Println("Usage: netweather diff [db-options] [-format text|json] <runA> <runB>")
+		return 1
+	}
+
+	var runIDs [2]int64
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || id <= 0 {
+			fmt.Printf("Invalid scan run ID %q\n", arg)
+			return 1
+		}
+		if _, err := store.ScanRun(id); err != nil {
+			fmt.Printf("Error retrieving scan run %d: %v\n", id, err)
+			return 1
+		}
+		runIDs[i] = id
+	}
+
+	var results [2][]ScanResult
+	var checks [2][]URLReachability
+	for i, id := range runIDs {
+		var err error
+		if results[i], err = getScanResults(id); err != nil {
+			fmt.Printf("Error retrieving results of run %d: %v\n", id, err)
+			return 1
+		}
+		if checks[i], err = store.URLReachabilityChecks(id); err != nil {
+			fmt.Printf("Error retrieving reachability checks of run %d: %v\n", id, err)
+			return 1
+		}
+	}
+
+	diff := diffScanResults(runIDs[0], runIDs[1], results[0], results[1], checks[0], checks[1])
+	switch format {
+	case "text", "":
+		writeRunDiffText(out, diff)
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diff); err != nil {
+			fmt.Printf("Error encoding diff: %v\n", err)
+			return 1
+		}
+	default:
+		fmt.Printf("Unsupported diff format %q (use text or json)\n", format)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffScanResultsReachability(t *testing.T) {
+	resultsA := []ScanResult{
+		{URL: "https://emptied.example/", ScriptURL: "https://emptied.example/jquery.js", LibraryName: "jquery", LibraryVersion: "3.4.1", Checksum: "a"},
+		{URL: "https://down.example/", ScriptURL: "https://down.example/app.js", LibraryName: "app", Checksum: "b"},
+		{URL: "https://dropped.example/", ScriptURL: "https://dropped.example/app.js", LibraryName: "app", Checksum: "c"},
+	}
+	checksA := []URLReachability{
+		{OriginalURL: "emptied.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://emptied.example/"},
+		{OriginalURL: "down.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://down.example/"},
+		{OriginalURL: "dropped.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://dropped.example/"},
+		{OriginalURL: "back.example", HTTPSAvailable: true, HTTPSStatusCode: 503},
+	}
+	resultsB := []ScanResult{
+		{URL: "https://back.example/", ScriptURL: "https://back.example/app.js", LibraryName: "app", Checksum: "d"},
+		{URL: "https://new.example/", ScriptURL: "https://new.example/app.js", LibraryName: "app", Checksum: "e"},
+	}
+	checksB := []URLReachability{
+		{OriginalURL: "emptied.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://emptied.example/"},
+		{OriginalURL: "down.example", HTTPSAvailable: false},
+		{OriginalURL: "back.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://back.example/"},
+		{OriginalURL: "new.example", HTTPSAvailable: true, HTTPSStatusCode: 200, FinalURL: "https://new.example/"},
+	}
+
+	diff := diffScanResults(1, 2, resultsA, resultsB, checksA, checksB)
+
+	if len(diff.Sites) != 1 || diff.Sites[0].URL != "https://emptied.example/" ||
+		len(diff.Sites[0].Changes) != 1 || diff.Sites[0].Changes[0].Kind != ChangeScriptRemoved {
+		t.Errorf("Sites = %+v, want the removed jquery of https://emptied.example/", diff.Sites)
+	}
+	lists := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"SitesAdded", diff.SitesAdded, []string{"https://new.example/"}},
+		{"SitesRemoved", diff.SitesRemoved, []string{"https://dropped.example/"}},
+		{"SitesUnreachable", diff.SitesUnreachable, []string{"https://down.example/"}},
+		{"SitesRecovered", diff.SitesRecovered, []string{"https://back.example/"}},
+	}
+	for _, l := range lists {
+		if !reflect.DeepEqual(l.got, l.want) {
+			t.Errorf("%s = %q, want %q", l.name, l.got, l.want)
+		}
+	}
+}
+
+func TestDiffSiteScripts(t *testing.T) {
+	snapshot := func(name, version, checksum string) ScriptSnapshot {
+		return ScriptSnapshot{LibraryName: name, LibraryVersion: version, Checksum: checksum}
+	}
+	// change renders a change as kind:url[<previous url]
+	change := func(c ScriptChange) string {
+		s := c.Kind + ":" + c.ScriptURL
+		if c.PreviousURL != "" {
+			s += "<" + c.PreviousURL
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		before map[string]ScriptSnapshot
+		after  map[string]ScriptSnapshot
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			before: map[string]ScriptSnapshot{"/a.js": snapshot("jquery", "3.7.1", "a")},
+			after:  map[string]ScriptSnapshot{"/a.js": snapshot("jquery", "3.7.1", "a")},
+		},
+		{
+			name:   "upgrade in place",
+			before: map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "3.6.0", "a")},
+			after:  map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "3.7.1", "b")},
+			want:   []string{"upgraded:/jq.js"},
+		},
+		{
+			name:   "downgrade in place",
+			before: map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "3.7.1", "a")},
+			after:  map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "1.12.4", "b")},
+			want:   []string{"downgraded:/jq.js"},
+		},
+		{
+			name:   "unknown version",
+			before: map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "unknown", "a")},
+			after:  map[string]ScriptSnapshot{"/jq.js": snapshot("jquery", "3.7.1", "b")},
+			want:   []string{"version-changed:/jq.js"},
+		},
+		{
+			name:   "other library at the same URL",
+			before: map[string]ScriptSnapshot{"/lib.js": snapshot("jquery", "3.7.1", "a")},
+			after:  map[string]ScriptSnapshot{"/lib.js": snapshot("lodash", "4.17.21", "b")},
+			want:   []string{"version-changed:/lib.js"},
+		},
+		{
+			name:   "content changed",
+			before: map[string]ScriptSnapshot{"/app.js": snapshot("app", "", "a")},
+			after:  map[string]ScriptSnapshot{"/app.js": snapshot("app", "", "b")},
+			want:   []string{"checksum-changed:/app.js"},
+		},
+		{
+			name:   "moved to a new CDN URL",
+			before: map[string]ScriptSnapshot{"/3.6.0/jquery.js": snapshot("jquery", "3.6.0", "a")},
+			after:  map[string]ScriptSnapshot{"/3.7.1/jquery.js": snapshot("jquery", "3.7.1", "b")},
+			want:   []string{"upgraded:/3.7.1/jquery.js</3.6.0/jquery.js"},
+		},
+		{
+			name:   "same version at a new URL is not paired",
+			before: map[string]ScriptSnapshot{"/old/jquery.js": snapshot("jquery", "3.7.1", "a")},
+			after:  map[string]ScriptSnapshot{"/new/jquery.js": snapshot("jquery", "3.7.1", "a")},
+			want:   []string{"removed:/old/jquery.js", "added:/new/jquery.js"},
+		},
+		{
+			name:   "unidentified scripts are not paired",
+			before: map[string]ScriptSnapshot{"/a.js": snapshot("unknown", "1.0.0", "a")},
+			after:  map[string]ScriptSnapshot{"/b.js": snapshot("unknown", "2.0.0", "b")},
+			want:   []string{"removed:/a.js", "added:/b.js"},
+		},
+		{
+			name: "pairs in URL order, leftovers stay",
+			before: map[string]ScriptSnapshot{
+				"/1/jquery.js": snapshot("jquery", "1.12.4", "a"),
+				"/2/jquery.js": snapshot("jquery", "2.2.4", "b"),
+			},
+			after: map[string]ScriptSnapshot{
+				"/3/jquery.js":  snapshot("jquery", "3.7.1", "c"),
+				"/lodash.js":    snapshot("lodash", "4.17.21", "d"),
+				"inline:abcdef": snapshot("unknown", "", "e"),
+			},
+			want: []string{"upgraded:/3/jquery.js</1/jquery.js", "removed:/2/jquery.js", "added:/lodash.js", "added:inline:abcdef"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range diffSiteScripts(tt.before, tt.after) {
+				got = append(got, change(c))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryUserAgent is sent with discovery requests and selects the
+// robots.txt group that applies to the scanner
+const discoveryUserAgent = "netweather"
+
+// Limits of the discovery stage per host
+const (
+	maxSitemapFetches = 10               // Sitemaps and nested sitemap indexes
+	maxSitemapSize    = 50 * 1024 * 1024 // Uncompressed size allowed by the sitemap protocol
+	maxRobotsSize     = 512 * 1024
+)
+
+// discoveryTimeout bounds each robots.txt and sitemap request
+var discoveryTimeout = 10 * time.Second
+
+// robotsRule is an Allow or Disallow line of robots.txt
+type robotsRule struct {
+	allow   bool
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// robotsTxt holds the rules of the group that applies to the scanner and
+// the sitemaps listed in robots.txt
+type robotsTxt struct {
+	rules    []robotsRule
+	sitemaps []string
+}
+
+// parseRobotsTxt parses robots.txt, keeping the rules of the group naming
+// the scanner or, if there is none, of the * group
+func parseRobotsTxt(content []byte) *robotsTxt {
+	robots := &robotsTxt{}
+	var specific, wildcard []robotsRule
+	hasSpecific := false
+
+	// Consecutive User-agent lines open one group
+	var agents []string
+	inRules := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// An empty Disallow allows everything
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value, regex: robotsPattern(value)}
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					wildcard = append(wildcard, rule)
+				case strings.Contains(discoveryUserAgent, agent) || strings.Contains(agent, discoveryUserAgent):
+					specific = append(specific, rule)
+					hasSpecific = true
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				robots.sitemaps = append(robots.sitemaps, value)
+			}
+		}
+	}
+
+	// Only the most specific group applies
+	robots.rules = wildcard
+	if hasSpecific {
+		robots.rules = specific
+	}
+	return robots
+}
+
+// robotsPattern compiles a robots.txt path pattern, where * matches any
+// sequence of characters and a trailing $ anchors the end of the path
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// Allowed reports whether the scanner may fetch the URL. The longest
+// matching rule wins, and Allow wins ties.
+func (r *robotsTxt) Allowed(u *url.URL) bool {
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !rule.regex.MatchString(target) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// sitemapDocument is a sitemap (<urlset>) or a sitemap index (<sitemapindex>)
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLocation `xml:"url"`
+	Sitemaps []sitemapLocation `xml:"sitemap"`
+}
+
+// sitemapLocation is a <url> or <sitemap> entry
+type sitemapLocation struct {
+	Loc string `xml:"loc"`
+}
+
+// hostDiscovery collects the pages of one host
+type hostDiscovery struct {
+	ctx      context.Context
+	client   *http.Client
+	base     *url.URL // Scheme and host of the site
+	robots   *robotsTxt
+	maxPages int
+	fetches  int
+	known    map[string]bool // URLs already in the job list, shared between hosts
+	seen     map[string]bool
+	pages    []string
+}
+
+// fetch downloads a discovery resource, decompressing gzip content
+func (d *hostDiscovery) fetch(resourceURL string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", discoveryUserAgent)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+	// Sitemaps may be served as .xml.gz; the Go client already removes
+	// transfer compression, so check the content itself
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(io.LimitReader(reader, maxSitemapSize))
+	}
+	return body, nil
+}
+
+// readSitemap adds the pages of a sitemap, following sitemap indexes
+func (d *hostDiscovery) readSitemap(sitemapURL string) {
+	if len(d.pages) >= d.maxPages || d.fetches >= maxSitemapFetches || d.seen["sitemap:"+sitemapURL] {
+		return
+	}
+	d.seen["sitemap:"+sitemapURL] = true
+	d.fetches++
+
+	content, err := d.fetch(sitemapURL, maxSitemapSize)
+	if err != nil {
+		logger.Printf("Error fetching sitemap %s: %v\n", sitemapURL, err)
+		return
+	}
+	var doc sitemapDocument
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		logger.Printf("Error parsing sitemap %s: %v\n", sitemapURL, err)
+		return
+	}
+
+	for _, entry := range doc.URLs {
+		if len(d.pages) >= d.maxPages {
+			return
+		}
+		d.addPage(strings.TrimSpace(entry.Loc))
+	}
+	for _, entry := range doc.Sitemaps {
+		d.readSitemap(strings.TrimSpace(entry.Loc))
+	}
+}
+
+// addPage records a sitemap page if it belongs to the host, is allowed by
+// robots.txt and has not been seen yet
+func (d *hostDiscovery) addPage(loc string) {
+	page, err := url.Parse(loc)
+	if err != nil || page.Host == "" {
+		return
+	}
+	if !strings.EqualFold(page.Hostname(), d.base.Hostname()) {
+		return
+	}
+	page.Fragment = ""
+	key := page.String()
+	if d.known[key] || d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+
+	if !d.robots.Allowed(page) {
+		logger.Printf("Skipping %s: disallowed by robots.txt\n", key)
+		return
+	}
+	d.pages = append(d.pages, key)
+}
+
+// discoverHost returns up to maxPages pages of the site from its sitemaps.
+// known holds the URLs already in the job list.
+func discoverHost(ctx context.Context, base *url.URL, maxPages int, known map[string]bool) []string {
+	d := &hostDiscovery{
+		ctx:      ctx,
+		client:   newDiscoveryClient(),
+		base:     base,
+		maxPages: maxPages,
+		known:    known,
+		seen:     make(map[string]bool),
+	}
+	d.robots = d.loadRobotsTxt()
+
+	root := base.Scheme + "://" + base.Host
+	sitemaps := d.robots.sitemaps
+	if len(sitemaps) == 0 {
+		sitemaps = []string{root + "/sitemap.xml"}
+	}
+	for _, sitemap := range sitemaps {
+		d.readSitemap(sitemap)
+	}
+	return d.pages
+}
+
+// newDiscoveryClient returns the HTTP client for robots.txt and sitemaps
+func newDiscoveryClient() *http.Client {
+	return &http.Client{
+		Timeout: discoveryTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// loadRobotsTxt fetches and parses the robots.txt of the site. Without a
+// readable robots.txt everything is allowed.
+func (d *hostDiscovery) loadRobotsTxt() *robotsTxt {
+	root := d.base.Scheme + "://" + d.base.Host
+	content, err := d.fetch(root+"/robots.txt", maxRobotsSize)
+	if err != nil {
+		logger.Printf("No robots.txt for %s: %v\n", root, err)
+		return &robotsTxt{}
+	}
+	return parseRobotsTxt(content)
+}
+
+// fetchRobotsTxt returns the robots.txt rules of the site of base
+func fetchRobotsTxt(ctx context.Context, base *url.URL) *robotsTxt {
+	d := &hostDiscovery{ctx: ctx, client: newDiscoveryClient(), base: base}
+	return d.loadRobotsTxt()
+}
+
+// discoveryBase returns the scheme and host of an input URL. URLs without a
+// protocol are assumed to be served over HTTPS.
+func discoveryBase(inputURL string) (*url.URL, error) {
+	if !strings.Contains(inputURL, "://") {
+		inputURL = "https://" + inputURL
+	}
+	parsed, err := url.Parse(inputURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("no host in %q", inputURL)
+	}
+	return &url.URL{Scheme: parsed.Scheme, Host: parsed.Host}, nil
+}
+
+// discoverPages expands the job list with up to maxPages pages per host
+// found in the hosts' sitemaps, honoring robots.txt. Discovered pages follow
+// the input URL of their host, inherit its owner and tags and record it as
+// their root. Hosts are processed by up to workers goroutines.
+func discoverPages(ctx context.Context, jobs []URLJob, maxPages, workers int) []URLJob {
+	if maxPages <= 0 {
+		return jobs
+	}
+
+	known := make(map[string]bool)
+	for _, job := range jobs {
+		known[job.URL] = true
+	}
+
+	// One discovery per host, attributed to its first input URL
+	type hostTask struct {
+		index int
+		base  *url.URL
+		pages []string
+	}
+	var tasks []*hostTask
+	hosts := make(map[string]bool)
+	for i, job := range jobs {
+		if exclusionRule(job.URL) != "" {
+			continue
+		}
+		base, err := discoveryBase(job.URL)
+		if err != nil {
+			logger.Printf("Skipping discovery for %s: %v\n", job.URL, err)
+			continue
+		}
+		host := strings.ToLower(base.Host)
+		if hosts[host] {
+			continue
+		}
+		hosts[host] = true
+		tasks = append(tasks, &hostTask{index: i, base: base})
+	}
+
+	if workers <= 0 {
+		workers = 1
+	}
+	queue := make(chan *hostTask)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(tasks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				task.pages = discoverHost(ctx, task.base, maxPages, known)
+				logger.Printf("Discovered %d pages on %s\n", len(task.pages), task.base.Host)
+			}
+		}()
+	}
+sending:
+	for _, task := range tasks {
+		select {
+		case queue <- task:
+		case <-ctx.Done():
+			break sending
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	discovered := make(map[int][]string)
+	total := 0
+	for _, task := range tasks {
+		discovered[task.index] = task.pages
+		total += len(task.pages)
+	}
+	if total == 0 {
+		return jobs
+	}
+
+	expanded := make([]URLJob, 0, len(jobs)+total)
+	seen := make(map[string]bool)
+	for i, job := range jobs {
+		expanded = append(expanded, job)
+		for _, page := range discovered[i] {
+			if seen[page] {
+				continue
+			}
+			seen[page] = true
+			expanded = append(expanded, URLJob{URL: page, Owner: job.Owner, Tags: job.Tags, Root: job.URL})
+		}
+	}
+	for i := range expanded {
+		expanded[i].Index, expanded[i].OriginalIndex = i, i
+	}
+	logger.Printf("Discovery added %d pages on %d hosts\n", total, len(tasks))
+	return expanded
+}
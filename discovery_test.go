@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRobotsTxtAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		paths  map[string]bool // Path and query to whether it is allowed
+	}{
+		{
+			name:   "no rules",
+			robots: "",
+			paths:  map[string]bool{"/": true, "/private/": true},
+		},
+		{
+			name:   "wildcard group",
+			robots: "User-agent: *\nDisallow: /private/\nAllow: /private/ok.html\n",
+			paths:  map[string]bool{"/": true, "/private/": false, "/private/x.html": false, "/private/ok.html": true, "/privateer": true},
+		},
+		{
+			name: "group naming the scanner replaces the wildcard group",
+			robots: "User-agent: *\nDisallow: /\n\n" +
+				"User-agent: NetWeather/1.0\nDisallow: /admin\n",
+			paths: map[string]bool{"/": true, "/page.html": true, "/admin/users": false},
+		},
+		{
+			name: "consecutive user agents share a group",
+			robots: "User-agent: googlebot\nUser-agent: netweather\nDisallow: /shared\n\n" +
+				"User-agent: googlebot\nDisallow: /google-only\n",
+			paths: map[string]bool{"/shared/a": false, "/google-only": true},
+		},
+		{
+			name:   "other agents are ignored",
+			robots: "User-agent: googlebot\nDisallow: /\n",
+			paths:  map[string]bool{"/": true, "/anything": true},
+		},
+		{
+			name:   "empty disallow allows everything",
+			robots: "User-agent: *\nDisallow:\n",
+			paths:  map[string]bool{"/": true, "/private/": true},
+		},
+		{
+			name:   "longest match wins, allow wins ties",
+			robots: "User-agent: *\nAllow: /docs\nDisallow: /docs/\nDisallow: /shop\nAllow: /shop\n",
+			paths:  map[string]bool{"/docs": true, "/docs/a": false, "/shop/cart": true},
+		},
+		{
+			name:   "wildcards",
+			robots: "User-agent: *\nDisallow: /*.pdf\nDisallow: /search*q=\nAllow: /public/*.pdf\n",
+			paths:  map[string]bool{"/files/a.pdf": false, "/files/a.pdf.html": false, "/public/a.pdf": true, "/search?q=x": false, "/search?page=2": true},
+		},
+		{
+			name:   "end anchor",
+			robots: "User-agent: *\nDisallow: /*.php$\nDisallow: /exact$\n",
+			paths:  map[string]bool{"/index.php": false, "/index.php?x=1": true, "/index.php5": true, "/exact": false, "/exact/more": true},
+		},
+		{
+			name:   "comments and case",
+			robots: "# robots\nUSER-AGENT: * # everyone\nDISALLOW: /tmp # scratch\n",
+			paths:  map[string]bool{"/tmp/a": false, "/TMP/a": true},
+		},
+		{
+			name:   "special characters are literal",
+			robots: "User-agent: *\nDisallow: /a.b+(c)\n",
+			paths:  map[string]bool{"/a.b+(c)": false, "/aXb+(c)": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robots := parseRobotsTxt([]byte(tt.robots))
+			for target, want := range tt.paths {
+				u, err := url.Parse("https://example.com" + target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := robots.Allowed(u); got != want {
+					t.Errorf("Allowed(%q) = %v, want %v", target, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRobotsTxtSitemaps(t *testing.T) {
+	robots := parseRobotsTxt([]byte("Sitemap: https://example.com/sitemap.xml\nUser-agent: *\nDisallow: /x\nsitemap: https://example.com/news.xml\nSitemap:\n"))
+	want := []string{"https://example.com/sitemap.xml", "https://example.com/news.xml"}
+	if !reflect.DeepEqual(robots.sitemaps, want) {
+		t.Errorf("sitemaps = %q, want %q", robots.sitemaps, want)
+	}
+}
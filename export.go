@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// inventoryCSVHeader names the columns of the library inventory CSV
+var inventoryCSVHeader = []string{
+	"site", "script_url", "library_name", "library_version", "checksum", "identified_by", "scanned_at", "owner", "tags", "root_url",
+}
+
+// writeInventoryCSV writes one library inventory row per scan result
+func writeInventoryCSV(w *csv.Writer, results []ScanResult) error {
+	for _, r := range results {
+		scannedAt := ""
+		if !r.ScannedAt.IsZero() {
+			scannedAt = r.ScannedAt.UTC().Format(time.RFC3339)
+		}
+		row := []string{r.URL, r.ScriptURL, r.LibraryName, r.LibraryVersion, r.Checksum, r.IdentifiedBy, scannedAt, r.Owner, strings.Join(r.Tags, ";"), r.RootURL}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runExportCommand implements "netweather export csv" over the stored scan
+// results of one run (or all runs for runID 0) and returns the process exit code
+func runExportCommand(args []string, runID int64, outputFile string) int {
+	if len(args) != 1 || args[0] != OutputCSV {
+		fmt.Println("Usage: netweather export [db-options] [-run id] [-output-file file] csv")
+		return 1
+	}
+
+	results, err := getScanResults(runID)
+	if err != nil {
+		fmt.Printf("Error retrieving scan results: %v\n", err)
+		return 1
+	}
+
+	writer, err := NewResultWriter(OutputCSV, outputFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if err := writeInventoryCSV(writer.csv, results); err != nil {
+		writer.Close()
+		fmt.Printf("Error writing CSV: %v\n", err)
+		return 1
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Printf("Error writing CSV: %v\n", err)
+		return 1
+	}
+
+	if !writer.ToStdout() {
+		fmt.Printf("Exported %d rows to %s\n", len(results), outputFile)
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Input formats of URL lists
+const (
+	InputText = "text" // One URL per line
+	InputCSV  = "csv"  // Header with a url column, optional owner and tags columns
+	InputJSON = "json" // Array of URL strings or {"url", "owner", "tags"} objects
+)
+
+// stdinInput is the file name that reads the URL list from stdin
+const stdinInput = "-"
+
+// urlInputRecord is one element of a JSON URL list
+type urlInputRecord struct {
+	URL   string   `json:"url"`
+	Owner string   `json:"owner"`
+	Tags  []string `json:"tags"`
+}
+
+// readURLInput reads the URLs to scan from a file, or from stdin for "-".
+// The format is taken from the .csv or .json extension and detected from
+// the content otherwise.
+func readURLInput(path string) ([]URLJob, error) {
+	var content []byte
+	var err error
+	if path == stdinInput {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	format := ""
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		format = InputCSV
+	case ".json":
+		format = InputJSON
+	}
+	return parseURLInput(content, format)
+}
+
+// parseURLInput parses a URL list in the given format, detecting the format
+// from the content if it is empty. Blank lines and # comments are skipped.
+func parseURLInput(content []byte, format string) ([]URLJob, error) {
+	if format == "" {
+		format = detectInputFormat(content)
+	}
+
+	var jobs []URLJob
+	var err error
+	switch format {
+	case InputText:
+		jobs, err = parseTextInput(content)
+	case InputCSV:
+		jobs, err = parseCSVInput(content)
+	case InputJSON:
+		jobs, err = parseJSONInput(content)
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		jobs[i].Index = i
+		jobs[i].OriginalIndex = i
+	}
+	return jobs, nil
+}
+
+// detectInputFormat recognises JSON arrays and CSV lists whose header has a
+// url column; everything else is read as plain text
+func detectInputFormat(content []byte) string {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		return InputJSON
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		header, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err == nil && len(header) > 1 && csvColumn(header, "url") >= 0 {
+			return InputCSV
+		}
+		break
+	}
+	return InputText
+}
+
+// parseTextInput reads one URL per line; text after " #" is a comment
+func parseTextInput(content []byte) ([]URLJob, error) {
+	var jobs []URLJob
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		jobs = append(jobs, URLJob{URL: line})
+	}
+	return jobs, scanner.Err()
+}
+
+// parseCSVInput reads a CSV list with a header row. The url column is
+// required; owner and tags (separated by ; or ,) are optional and other
+// columns are ignored.
+func parseCSVInput(content []byte) ([]URLJob, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+	urlColumn := csvColumn(header, "url")
+	if urlColumn < 0 {
+		return nil, fmt.Errorf("CSV header has no url column")
+	}
+	ownerColumn := csvColumn(header, "owner")
+	tagsColumn := csvColumn(header, "tags")
+
+	var jobs []URLJob
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+		field := func(column int) string {
+			if column < 0 || column >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[column])
+		}
+
+		job := URLJob{URL: field(urlColumn), Owner: field(ownerColumn)}
+		if job.URL == "" {
+			continue
+		}
+		job.Tags = splitTags(field(tagsColumn))
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// parseJSONInput reads a JSON array whose elements are URL strings or
+// objects with url, owner and tags
+func parseJSONInput(content []byte) ([]URLJob, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(content, &elements); err != nil {
+		return nil, fmt.Errorf("error parsing JSON URL list: %v", err)
+	}
+
+	var jobs []URLJob
+	for i, element := range elements {
+		var record urlInputRecord
+		if bytes.HasPrefix(bytes.TrimSpace(element), []byte(`"`)) {
+			if err := json.Unmarshal(element, &record.URL); err != nil {
+				return nil, fmt.Errorf("error parsing JSON URL list element %d: %v", i+1, err)
+			}
+		} else if err := json.Unmarshal(element, &record); err != nil {
+			return nil, fmt.Errorf("error parsing JSON URL list element %d: %v", i+1, err)
+		}
+
+		record.URL = strings.TrimSpace(record.URL)
+		if record.URL == "" {
+			continue
+		}
+		// Tags are stored and exported as separated lists
+		for _, tag := range record.Tags {
+			if strings.ContainsAny(tag, ",;") {
+				return nil, fmt.Errorf("JSON URL list element %d: tag %q must not contain , or ;", i+1, tag)
+			}
+		}
+		jobs = append(jobs, URLJob{
+			URL:   record.URL,
+			Owner: strings.TrimSpace(record.Owner),
+			Tags:  normalizeTags(record.Tags),
+		})
+	}
+	return jobs, nil
+}
+
+// csvColumn returns the index of the named header column, or -1
+func csvColumn(header []string, name string) int {
+	for i, column := range header {
+		if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitTags splits a tag list separated by semicolons or commas
+func splitTags(value string) []string {
+	return normalizeTags(strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ',' }))
+}
+
+// normalizeTags trims tags and drops empty ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
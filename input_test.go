@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseURLInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		want    []URLJob
+		wantErr string
+	}{
+		{
+			name:    "text with comments",
+			content: "# sites\nhttps://a.example\n\n  b.example  # staging\n",
+			want:    []URLJob{{URL: "https://a.example"}, {URL: "b.example"}},
+		},
+		{
+			name:    "text that is not CSV",
+			content: "https://a.example/?x=1,2\n",
+			want:    []URLJob{{URL: "https://a.example/?x=1,2"}},
+		},
+		{
+			name:    "csv detected from header",
+			content: "# inventory\nURL,owner,tags,notes\nhttps://a.example,web-team,public; prod,ignored\nhttps://b.example\n,nobody,\n",
+			want: []URLJob{
+				{URL: "https://a.example", Owner: "web-team", Tags: []string{"public", "prod"}},
+				{URL: "https://b.example"},
+			},
+		},
+		{
+			name:    "csv tags separated by commas",
+			content: "url,tags\nhttps://a.example,\"public,prod\"\n",
+			format:  InputCSV,
+			want:    []URLJob{{URL: "https://a.example", Tags: []string{"public", "prod"}}},
+		},
+		{
+			name:    "csv without url column",
+			content: "site,owner\nhttps://a.example,web-team\n",
+			format:  InputCSV,
+			wantErr: "no url column",
+		},
+		{
+			name:    "json strings and objects",
+			content: `["https://a.example", {"url": " https://b.example ", "owner": "web-team", "tags": ["public", " "]}, {"url": ""}]`,
+			want: []URLJob{
+				{URL: "https://a.example"},
+				{URL: "https://b.example", Owner: "web-team", Tags: []string{"public"}},
+			},
+		},
+		{
+			name:    "json tag with comma",
+			content: `[{"url": "https://a.example", "tags": ["eu,us"]}]`,
+			wantErr: "must not contain",
+		},
+		{
+			name:    "json tag with semicolon",
+			content: `[{"url": "https://a.example", "tags": ["eu;us"]}]`,
+			wantErr: "must not contain",
+		},
+		{
+			name:    "invalid json",
+			content: `[{"url": }]`,
+			wantErr: "error parsing JSON",
+		},
+		{
+			name:    "unsupported format",
+			content: "https://a.example",
+			format:  "xml",
+			wantErr: "unsupported input format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := parseURLInput([]byte(tt.content), tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				tt.want[i].Index, tt.want[i].OriginalIndex = i, i
+			}
+			if !reflect.DeepEqual(jobs, tt.want) {
+				t.Errorf("got %+v, want %+v", jobs, tt.want)
+			}
+		})
+	}
+}
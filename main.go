@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -22,93 +21,249 @@ func main() {
 	// Define command line flags
 	var (
 		useDB       = flag.Bool("db", false, "Activate database storage")
+		dbDriver    = flag.String("db-driver", "", "Database driver: mysql, postgres or sqlite")
+		dbSSLMode   = flag.String("db-sslmode", "", "SSL mode for postgres connections")
+		dbPath      = flag.String("db-path", "", "Database file (sqlite only)")
 		dbHost      = flag.String("db-host", "", "Database host")
 		dbPort      = flag.String("db-port", "", "Database port")
 		dbUser      = flag.String("db-user", "", "Database user")
 		dbPassword  = flag.String("db-password", "", "Database password")
 		dbName      = flag.String("db-name", "", "Database name")
 		stats       = flag.Bool("stats", false, "Show statistics of scanned URLs")
+		statsRun    = flag.Int64("run", 0, "Limit statistics and exports to a single scan run ID")
+		format      = flag.String("format", "text", "Output format of the diff (text or json) and report (html) commands")
 		portScan    = flag.Bool("port-scan", false, "Enable port scanning with nmap")
 		scanPorts   = flag.String("scan-ports", "80,443,8080,8443", "Ports to scan (default: common web ports)")
 		nmapOptions = flag.String("nmap-options", "", "Additional nmap options")
+		portWorkers = flag.Int("port-scan-workers", defaultPortScanWorkers, "Number of nmap batches running at once in parallel mode")
+		portBatch   = flag.Int("port-scan-batch", defaultPortScanBatchSize, "Hosts per nmap batch in parallel mode")
 		useRemoteDB = flag.Bool("remote-db", false, "Use remote entries.db from GitHub instead of local file")
 		verbose     = flag.Bool("verbose", false, "Enable verbose output (shows all URLs including non-200 responses)")
+		vulnDBPath  = flag.String("vuln-db", "", "retire.js-style advisory file (jsrepository.json) for vulnerability matching")
+		sbomFormat  = flag.String("sbom", "", "Write a software bill of materials per scanned site (cyclonedx or spdx)")
+		sbomDir     = flag.String("sbom-dir", "sbom", "Directory for SBOM files")
+		outputFormat = flag.String("output", "", "Write machine-readable results: json, ndjson, csv or sarif")
+		outputFile  = flag.String("output-file", "", "File for -output records (default: stdout)")
+		listenAddr  = flag.String("listen", "", "Address of the serve API or the daemon health endpoint (default: 127.0.0.1:8090)")
+		configFile  = flag.String("config", "", "YAML configuration file (default: netweather.yaml if present)")
+		profile     = flag.String("profile", "", "Configuration profile to apply on top of the file's settings")
+		rulesFile   = flag.String("rules", "", "YAML file with URL include/exclude rules")
+		discover    = flag.Int("discover-pages", 0, "Add up to N pages per host from robots.txt and sitemap.xml (0 disables discovery)")
+		crawlDepth  = flag.Int("crawl-depth", 0, "Follow same-origin links up to N levels from each scanned page (0 disables crawling)")
+		crawlPages  = flag.Int("crawl-pages", defaultCrawlPages, "Pages to scan per site when crawling, including the first")
 		// Parallelization flags
 		workers     = flag.Int("workers", 8, "Number of concurrent URL workers")
 		requestDelay = flag.Int("request-delay", 100, "Delay between requests in milliseconds")
-		batchSize   = flag.Int("batch-size", 50, "Database batch size for bulk operations")
+		batchSize   = flag.Int("batch-size", defaultWriteBatchSize, "Rows per multi-row database INSERT in parallel mode")
 		sequential  = flag.Bool("sequential", false, "Force sequential processing (disable parallelization)")
+		scriptWorkers = flag.Int("script-workers", defaultPageScriptWorkers, "Scripts of a page fetched concurrently")
+		scriptFetches = flag.Int("script-fetches", defaultScriptFetchLimit, "Script downloads in flight across all pages")
+		scriptCacheMB = flag.Int("script-cache-mb", defaultScriptCacheMB, "Memory for cached scripts in megabytes (0 disables the memory cache)")
+		scriptCacheDir = flag.String("script-cache-dir", "", "Directory keeping downloaded scripts between runs")
 	)
-	flag.Parse()
+	// Subcommands may follow options: netweather [options] migrate [up|status]
+	command, args := splitCommand(flag.CommandLine, os.Args[1:])
+	args = parseArgs(flag.CommandLine, args)
 
 	initLogger("netweather.log")
 	logger.Println("Application started")
 
+	// Load .env file if it exists
+	if err := godotenv.Load(); err != nil {
+		logger.Println("No .env file found")
+	}
+
+	// Settings from the configuration file fill in flags that were given
+	// neither on the command line nor through the environment
+	configPath := configPath(*configFile)
+	if command == "config" {
+		os.Exit(runConfigCommand(args, configPath))
+	}
+	if err := applyConfigFile(configPath, getConfigValue(*profile, "NETWEATHER_PROFILE", "")); err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Exclusions from the configuration and the rules file
+	if err := configureURLRules(*rulesFile); err != nil {
+		fmt.Printf("Error loading URL rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	setScriptConcurrency(*scriptWorkers, *scriptFetches)
+	if err := configureScriptCache(*scriptCacheMB, *scriptCacheDir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Configure remote database if flag is set
 	if *useRemoteDB {
 		SetRemoteDB(true)
 		logger.Println("Remote database mode enabled")
 	}
 
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		logger.Println("No .env file found")
-	}
+	// Configure the vulnerability advisory database
+	SetVulnerabilityDBPath(getConfigValue(*vulnDBPath, "VULN_DB", "jsrepository.json"))
 
-	fmt.Println("NetWeather - URL Scanner")
+	// Keep machine-readable command output clean
+	if *format == "json" || *outputFormat != "" || (command == "export" && *outputFile == "") {
+		fmt.Fprintln(os.Stderr, "NetWeather - URL Scanner")
+	} else {
+		fmt.Println("NetWeather - URL Scanner")
+	}
 	
 	// Check if stats flag is set
-	if *stats {
-		// Stats mode requires database connection
+	if *stats || commandNeedsDB(command, args) {
+		// Stats and database commands require database connection
 		*useDB = true
 	}
 
 	// Initialize database if flag is set or stats is requested
 	if *useDB || *stats {
-		// Get database credentials from command line or environment variables
-		host := getConfigValue(*dbHost, "DB_HOST", "127.0.0.1")
-		port := getConfigValue(*dbPort, "DB_PORT", "3306")
-		user := getConfigValue(*dbUser, "DB_USER", "")
-		password := getConfigValue(*dbPassword, "DB_PASSWORD", "")
-		database := getConfigValue(*dbName, "DB_NAME", "")
-
-		if user == "" || database == "" {
-			logger.Fatal("Database user and name must be provided via command line or environment variables")
+		// Get database settings from command line or environment variables
+		driver := getConfigValue(*dbDriver, "DB_DRIVER", "mysql")
+		config := DBConfig{
+			Driver:   driver,
+			Host:     getConfigValue(*dbHost, "DB_HOST", "127.0.0.1"),
+			Port:     getConfigValue(*dbPort, "DB_PORT", defaultDBPort(driver)),
+			User:     getConfigValue(*dbUser, "DB_USER", ""),
+			Password: getConfigValue(*dbPassword, "DB_PASSWORD", ""),
+			Name:     getConfigValue(*dbName, "DB_NAME", ""),
+			Path:     getConfigValue(*dbPath, "DB_PATH", "netweather.db"),
+			SSLMode:  getConfigValue(*dbSSLMode, "DB_SSLMODE", "disable"),
 		}
 
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, database)
-		if err := initDB(dsn); err != nil {
+		if err := initDB(config); err != nil {
 			logger.Fatalf("Could not initialize database: %v", err)
 		}
 
-		if err := createTable(); err != nil {
-			logger.Fatalf("Could not create table: %v", err)
+		// The migrate command applies migrations itself
+		if command != "migrate" {
+			if err := createTable(); err != nil {
+				logger.Fatalf("Could not create table: %v", err)
+			}
 		}
 	}
 
+	switch command {
+	case "migrate":
+		os.Exit(runMigrateCommand(args))
+	case "diff":
+		os.Exit(runDiffCommand(args, *format, os.Stdout))
+	case "export":
+		os.Exit(runExportCommand(args, *statsRun, *outputFile))
+	case "report":
+		os.Exit(runReportCommand(args, *format, *statsRun, *outputFile))
+	case "serve":
+		os.Exit(runServeCommand(args, ServeConfig{
+			Listen:        getConfigValue(*listenAddr, "LISTEN_ADDR", defaultListenAddr),
+			MaxWorkers:    *workers,
+			RequestDelay:  time.Duration(*requestDelay) * time.Millisecond,
+			BatchSize:     *batchSize,
+			UseDB:         *useDB,
+			DiscoverPages: *discover,
+			Crawl:         CrawlConfig{Depth: *crawlDepth, MaxPages: *crawlPages},
+		}))
+	case "daemon":
+		os.Exit(runDaemonCommand(args, ServeConfig{
+			Listen:        getConfigValue(*listenAddr, "LISTEN_ADDR", ""),
+			MaxWorkers:    *workers,
+			RequestDelay:  time.Duration(*requestDelay) * time.Millisecond,
+			BatchSize:     *batchSize,
+			UseDB:         true,
+			DiscoverPages: *discover,
+			Crawl:         CrawlConfig{Depth: *crawlDepth, MaxPages: *crawlPages},
+		}))
+	}
+
 	// If stats flag is set, show statistics and exit
 	if *stats {
-		showStatistics()
+		showStatistics(*statsRun)
 		os.Exit(0)
 	}
 
 	// Regular scanning mode requires a URL file
-	if flag.NArg() < 1 {
+	if len(args) < 1 {
 		printHelp()
 		os.Exit(1)
 	}
 
-	filePath := flag.Arg(0)
-	urls, err := readLines(filePath)
+	filePath := args[0]
+	jobs, err := readURLInput(filePath)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Set up SBOM output if requested
+	var sbomWriter *SBOMWriter
+	if *sbomFormat != "" {
+		sbomWriter, err = NewSBOMWriter(*sbomFormat, *sbomDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Set up machine-readable output if requested
+	var resultWriter *ResultWriter
+	var progress io.Writer = os.Stdout
+	if *outputFormat != "" {
+		resultWriter, err = NewResultWriter(*outputFormat, *outputFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Records own stdout, so progress and summaries go to stderr
+		if resultWriter.ToStdout() {
+			progress = os.Stderr
+		}
+		defer func() {
+			if err := resultWriter.Close(); err != nil {
+				logger.Printf("Error closing output: %v\n", err)
+			}
+		}()
+	}
+
+	// Expand homepages with deeper pages from the hosts' sitemaps
+	if *discover > 0 {
+		inputCount := len(jobs)
+		jobs = discoverPages(context.Background(), jobs, *discover, *workers)
+		fmt.Fprintf(progress, "Discovered %d additional pages from sitemaps\n", len(jobs)-inputCount)
+	}
+
+	// Record the invocation as a scan run so stored rows can be grouped by run
+	var run *ScanRun
+	if *useDB {
+		run = &ScanRun{
+			StartedAt: time.Now(),
+			InputFile: filePath,
+			Flags:     explicitFlags(),
+			Workers:   *workers,
+			TotalURLs: int64(len(jobs)),
+		}
+		if *sequential || *workers <= 1 {
+			run.Workers = 1
+		}
+		if err := startScanRun(run); err != nil {
+			logger.Printf("Error recording scan run: %v\n", err)
+			run = nil
+		} else {
+			logger.Printf("Started scan run %d\n", run.ID)
+		}
+	}
+	var runID int64
+	if run != nil {
+		runID = run.ID
+	}
+
+	crawl := CrawlConfig{Depth: *crawlDepth, MaxPages: *crawlPages, Delay: time.Duration(*requestDelay) * time.Millisecond}
+
 	// Choose between sequential and parallel processing
+	var processed, scanned, excluded, skipped, errors int64
 	if *sequential || *workers <= 1 {
 		// Sequential processing (original logic)
-		processURLsSequentially(urls, *useDB, *verbose, *portScan, *scanPorts, *nmapOptions)
+		processed, scanned, excluded, skipped, errors = processURLsSequentially(jobs, *useDB, *verbose, *portScan, *scanPorts, *nmapOptions, crawl, sbomWriter, runID, resultWriter, progress)
 	} else {
 		// Parallel processing (new logic)
 		config := ParallelConfig{
@@ -117,58 +272,93 @@ func main() {
 			BatchSize:    *batchSize,
 			UseDB:        *useDB,
 			Verbose:      *verbose,
+			SBOM:         sbomWriter,
+			RunID:        runID,
+			Output:       resultWriter,
+			Progress:     progress,
+			Crawl:        crawl,
+			PortScan: PortScanConfig{
+				Enabled:   *portScan,
+				Ports:     *scanPorts,
+				Options:   *nmapOptions,
+				Workers:   *portWorkers,
+				BatchSize: *portBatch,
+			},
 		}
 		
 		processor := NewParallelProcessor(config)
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
 		defer cancel()
 		
-		if err := processor.ProcessURLs(ctx, urls); err != nil {
+		if err := processor.ProcessJobs(ctx, jobs); err != nil {
 			logger.Printf("Error in parallel processing: %v\n", err)
-			fmt.Printf("Error in parallel processing: %v\n", err)
+			fmt.Fprintf(progress, "Error in parallel processing: %v\n", err)
 		}
-		
-		// Port scanning is handled within parallel processing for now
-		// TODO: Add parallel port scanning support
-		if *portScan {
-			logger.Println("Note: Port scanning in parallel mode is not yet implemented")
-			fmt.Println("Note: Port scanning in parallel mode is not yet implemented")
+		processed, scanned, excluded, skipped, errors = processor.Counts()
+	}
+
+	if run != nil {
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Processed, run.Scanned, run.Excluded, run.Skipped, run.Errors = processed, scanned, excluded, skipped, errors
+		if err := finishScanRun(run); err != nil {
+			logger.Printf("Error recording end of scan run %d: %v\n", run.ID, err)
+		} else if !*verbose {
+			fmt.Fprintf(progress, "Scan run ID: %d\n", run.ID)
 		}
 	}
 	logger.Println("Application finished")
 }
 
 // processURLsSequentially handles sequential URL processing (original logic)
-func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanPorts, nmapOptions string) {
-	totalURLs := len(urls)
+// and returns the same counters as ProgressTracker.GetCounts
+func processURLsSequentially(jobs []URLJob, useDB, verbose, portScan bool, scanPorts, nmapOptions string, crawl CrawlConfig, sbomWriter *SBOMWriter, runID int64, output *ResultWriter, progress io.Writer) (processed, scanned, excluded, skipped, errors int64) {
+	totalURLs := len(jobs)
+	crawlState := newCrawlRun(jobs)
 	processedCount := 0
 	scannedCount := 0
 	skippedCount := 0
 	excludedCount := 0
+	excludedByRule := make(map[string]int64)
 	errorCount := 0
 	
 	// Show initial progress in non-verbose mode
 	if !verbose {
-		fmt.Printf("Processing %d URLs...\n", totalURLs)
-		fmt.Print("Progress: ")
+		fmt.Fprintf(progress, "Processing %d URLs...\n", totalURLs)
+		fmt.Fprint(progress, "Progress: ")
 	}
 	
-	for _, url := range urls {
+	// emit writes the machine-readable record of a processed URL
+	emit := func(result URLResult, startTime time.Time) {
+		if output == nil {
+			return
+		}
+		result.ProcessTime = time.Since(startTime)
+		if err := output.Write(result); err != nil {
+			logger.Printf("Error writing output record for %s: %v\n", result.Job.URL, err)
+		}
+	}
+	
+	for _, job := range jobs {
+		url := job.URL
 		processedCount++
 		logger.Printf("Processing URL: %s\n", url)
+		startTime := time.Now()
 		
 		if verbose {
-			fmt.Printf("\nProcessing URL: %s\n", url)
+			fmt.Fprintf(progress, "\nProcessing URL: %s\n", url)
 		}
 		
 		// Check if URL should be excluded
-		if shouldExcludeURL(url) {
+		if rule := exclusionRule(url); rule != "" {
 			excludedCount++
-			logger.Printf("Skipping excluded URL: %s\n", url)
+			excludedByRule[rule]++
+			logger.Printf("Skipping excluded URL: %s (rule: %s)\n", url, rule)
 			if verbose {
-				fmt.Printf("  - Skipping excluded URL (Microsoft login domain)\n")
+				fmt.Fprintf(progress, "  - Skipping excluded URL (rule: %s)\n", rule)
 			}
-			updateProgress(processedCount, totalURLs, verbose)
+			emit(URLResult{Job: job, Excluded: true, ExcludedBy: rule}, startTime)
+			updateProgress(progress, processedCount, totalURLs, verbose)
 			continue
 		}
 		
@@ -178,11 +368,13 @@ func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanP
 			errorCount++
 			logger.Printf("Error checking reachability for %s: %v\n", url, err)
 			if verbose {
-				fmt.Printf("  - Error checking reachability: %v\n", err)
+				fmt.Fprintf(progress, "  - Error checking reachability: %v\n", err)
 			}
-			updateProgress(processedCount, totalURLs, verbose)
+			emit(URLResult{Job: job, Error: err}, startTime)
+			updateProgress(progress, processedCount, totalURLs, verbose)
 			continue
 		}
+		reachability.RunID = runID
 		
 		// Display reachability information
 		if reachability.HTTPAvailable || reachability.HTTPSAvailable {
@@ -194,20 +386,20 @@ func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanP
 				if reachability.HTTPSAvailable {
 					protocols = append(protocols, fmt.Sprintf("HTTPS (%d)", reachability.HTTPSStatusCode))
 				}
-				fmt.Printf("  - Reachable via: %s\n", strings.Join(protocols, ", "))
+				fmt.Fprintf(progress, "  - Reachable via: %s\n", strings.Join(protocols, ", "))
 				
 				if reachability.HTTPRedirectURL != "" || reachability.HTTPSRedirectURL != "" {
-					fmt.Printf("  - Redirects detected\n")
+					fmt.Fprintf(progress, "  - Redirects detected\n")
 				}
 				
 				if reachability.FinalURL != "" && reachability.FinalURL != url {
-					fmt.Printf("  - Final URL: %s\n", reachability.FinalURL)
+					fmt.Fprintf(progress, "  - Final URL: %s\n", reachability.FinalURL)
 				}
 			}
 		} else {
 			errorCount++
 			if verbose {
-				fmt.Printf("  - URL not reachable\n")
+				fmt.Fprintf(progress, "  - URL not reachable\n")
 			}
 			logger.Printf("URL %s is not reachable\n", url)
 			
@@ -217,7 +409,8 @@ func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanP
 					logger.Printf("Error storing reachability data for %s: %v\n", url, err)
 				}
 			}
-			updateProgress(processedCount, totalURLs, verbose)
+			emit(URLResult{Job: job, Reachability: reachability}, startTime)
+			updateProgress(progress, processedCount, totalURLs, verbose)
 			continue
 		}
 		
@@ -234,9 +427,10 @@ func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanP
 			logger.Printf("Skipping JavaScript scanning for %s - no HTTP 200 response (HTTP: %d, HTTPS: %d)\n", 
 				url, reachability.HTTPStatusCode, reachability.HTTPSStatusCode)
 			if verbose {
-				fmt.Printf("  - Skipping JavaScript scan (no HTTP 200 response)\n")
+				fmt.Fprintf(progress, "  - Skipping JavaScript scan (no HTTP 200 response)\n")
 			}
-			updateProgress(processedCount, totalURLs, verbose)
+			emit(URLResult{Job: job, Reachability: reachability, Skipped: true}, startTime)
+			updateProgress(progress, processedCount, totalURLs, verbose)
 			continue
 		}
 		
@@ -250,118 +444,196 @@ func processURLsSequentially(urls []string, useDB, verbose, portScan bool, scanP
 		logger.Printf("Scanning URL: %s\n", finalURL)
 		
 		if verbose {
-			fmt.Printf("  - Scanning for JavaScript libraries...\n")
+			fmt.Fprintf(progress, "  - Scanning for JavaScript libraries...\n")
 		} else {
 			// Show which URL we're scanning in non-verbose mode
-			fmt.Printf("\n[%d/%d] Scanning: %s", processedCount, totalURLs, finalURL)
+			fmt.Fprintf(progress, "\n[%d/%d] Scanning: %s", processedCount, totalURLs, finalURL)
 		}
 		
-		scanURL(finalURL, useDB, verbose)
+		scanResults := scanURL(progress, finalURL, job, crawl, crawlState, useDB, verbose, runID)
+		
+		if sbomWriter != nil {
+			if path, err := sbomWriter.Write(finalURL, scanResults); err != nil {
+				logger.Printf("Error writing SBOM for %s: %v\n", finalURL, err)
+			} else if verbose {
+				fmt.Fprintf(progress, "  - SBOM written to %s\n", path)
+			}
+		}
 		
 		// Perform port scan if enabled
+		var portResults []NmapResult
 		if portScan {
 			logger.Printf("Port scanning URL: %s\n", finalURL)
 			if verbose {
-				fmt.Printf("  - Port scanning: %s\n", finalURL)
+				fmt.Fprintf(progress, "  - Port scanning: %s\n", finalURL)
 			}
-			performPortScan(finalURL, scanPorts, nmapOptions)
+			portResults = performPortScan(progress, finalURL, scanPorts, nmapOptions, runID)
 		}
 		
+		emit(URLResult{Job: job, Reachability: reachability, ScanResults: scanResults, PortScan: portResults}, startTime)
+		
 		// For non-verbose mode, add newline after successful scan before progress continues
 		if !verbose {
-			fmt.Print("\nProgress: ")
+			fmt.Fprint(progress, "\nProgress: ")
 		}
-		updateProgress(processedCount, totalURLs, verbose)
+		updateProgress(progress, processedCount, totalURLs, verbose)
 	}
 	
 	// Final summary
 	if !verbose {
-		fmt.Printf("\n\nScan completed!\n")
-		fmt.Printf("Total URLs processed: %d\n", processedCount)
-		fmt.Printf("Successfully scanned: %d\n", scannedCount)
-		if excludedCount > 0 {
-			fmt.Printf("Excluded URLs: %d\n", excludedCount)
-		}
+		fmt.Fprintf(progress, "\n\nScan completed!\n")
+		fmt.Fprintf(progress, "Total URLs processed: %d\n", processedCount)
+		fmt.Fprintf(progress, "Successfully scanned: %d\n", scannedCount)
+		printExclusionSummary(progress, int64(excludedCount), excludedByRule)
 		if skippedCount > 0 {
-			fmt.Printf("Skipped (non-200): %d\n", skippedCount)
+			fmt.Fprintf(progress, "Skipped (non-200): %d\n", skippedCount)
 		}
 		if errorCount > 0 {
-			fmt.Printf("Errors/Unreachable: %d\n", errorCount)
+			fmt.Fprintf(progress, "Errors/Unreachable: %d\n", errorCount)
 		}
 	}
-}
 
-// shouldExcludeURL checks if a URL should be excluded from scanning
-func shouldExcludeURL(inputURL string) bool {
-	// List of excluded domains/patterns - add sensitive domains here
-	excludedDomains := []string{
-		"login.microsoftonline.com", // Microsoft Online login URLs
-	}
-	
-	// Parse the URL to extract the hostname
-	parsedURL, err := url.Parse(inputURL)
-	if err != nil {
-		// If we can't parse the URL, check the raw string
-		for _, domain := range excludedDomains {
-			if strings.Contains(strings.ToLower(inputURL), strings.ToLower(domain)) {
-				return true
-			}
-		}
-		return false
-	}
-	
-	hostname := strings.ToLower(parsedURL.Hostname())
-	
-	// Check if the hostname matches any excluded domain
-	for _, domain := range excludedDomains {
-		if hostname == strings.ToLower(domain) || strings.HasSuffix(hostname, "."+strings.ToLower(domain)) {
-			return true
-		}
-	}
-	
-	return false
+	return int64(processedCount), int64(scannedCount), int64(excludedCount), int64(skippedCount), int64(errorCount)
 }
 
 // updateProgress shows progress indicator for non-verbose mode
-func updateProgress(current, total int, verbose bool) {
+func updateProgress(progress io.Writer, current, total int, verbose bool) {
 	if !verbose {
 		// Simple progress dots
 		if current%10 == 0 || current == total {
-			fmt.Printf(" %d", current)
+			fmt.Fprintf(progress, " %d", current)
 		} else {
-			fmt.Print(".")
+			fmt.Fprint(progress, ".")
 		}
 	}
 }
 
-func scanURL(baseURL string, useDB bool, verbose bool) {
-	logger.Printf("Fetching URL %s\n", baseURL)
-	resp, err := http.Get(baseURL)
+// scanURL scans a page, and the pages crawled from it, for scripts, prints
+// and stores what it finds and returns the identified libraries
+func scanURL(progress io.Writer, baseURL string, job URLJob, crawl CrawlConfig, run *crawlRun, useDB bool, verbose bool, runID int64) []ScanResult {
+	// Results are attributed to the page and to the site they belong to
+	rootURL := job.Root
+	if rootURL == "" {
+		rootURL = baseURL
+	}
+
+	var results []ScanResult
+	scriptsFound := 0
+	pagesScanned := 0
+	err := crawlSite(context.Background(), baseURL, crawl, run, func(pageURL string, doc *html.Node) {
+		pagesScanned++
+		if verbose && pageURL != baseURL {
+			fmt.Fprintf(progress, "  - Crawled page: %s\n", pageURL)
+		}
+
+		for _, outcome := range inspectPageScripts(pageURL, collectPageScripts(doc)) {
+			result, err := outcome.Result, outcome.Err
+			if err != nil {
+				if verbose {
+					fmt.Fprintf(progress, "Error processing script %s: %v\n", outcome.Script.ScriptURL(pageURL), err)
+				}
+				continue
+			}
+			if result == nil {
+				// Inline script without a recognisable library
+				continue
+			}
+			scriptsFound++
+			result.RunID = runID
+			result.RootURL = rootURL
+			result.Owner, result.Tags = job.Owner, job.Tags
+			results = append(results, *result)
+
+			if verbose {
+				if result.IsInline {
+					fmt.Fprintf(progress, "  - Found inline script: %s, Checksum: %s\n", result.ScriptURL, result.Checksum)
+				} else {
+					fmt.Fprintf(progress, "  - Found script: %s, Checksum: %s\n", result.ScriptURL, result.Checksum)
+				}
+				if result.LibraryVersion != "unknown" && result.LibraryVersion != "" {
+					fmt.Fprintf(progress, "    Library: %s v%s (%s) [%s...]\n", result.LibraryName, result.LibraryVersion, result.IdentifiedBy, result.Checksum[:8])
+				} else {
+					fmt.Fprintf(progress, "    Library: %s (%s) [%s...]\n", result.LibraryName, result.IdentifiedBy, result.Checksum[:8])
+				}
+				if len(result.Vulnerabilities) > 0 {
+					fmt.Fprintf(progress, "    Vulnerable: %s\n", formatVulnerabilities(result.Vulnerabilities))
+				}
+			}
+
+			if useDB {
+				if err := storeResult(*result); err != nil {
+					logger.Printf("Error storing result for %s: %v\n", result.ScriptURL, err)
+				}
+			}
+		}
+	})
 	if err != nil {
 		logger.Printf("Error fetching URL %s: %v\n", baseURL, err)
 		if verbose {
-			fmt.Printf("Error fetching URL %s: %v\n", baseURL, err)
+			fmt.Fprintf(progress, "Error fetching URL %s: %v\n", baseURL, err)
 		}
-		return
+		return nil
 	}
-	defer resp.Body.Close()
-
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		logger.Printf("Error parsing HTML from %s: %v\n", baseURL, err)
-		if verbose {
-			fmt.Printf("Error parsing HTML from %s: %v\n", baseURL, err)
+	
+	// Show summary for non-verbose mode
+	if !verbose {
+		if pagesScanned > 1 {
+			fmt.Fprintf(progress, " → %d scripts found on %d pages", scriptsFound, pagesScanned)
+		} else {
+			fmt.Fprintf(progress, " → %d scripts found", scriptsFound)
 		}
-		return
 	}
+	
+	return results
+}
 
-	var scripts []string
+// pageScript is a <script> element found in a page. External scripts carry
+// their src attribute, inline scripts carry their body.
+type pageScript struct {
+	Src         string
+	Code        string
+	Inline      bool
+	InlineIndex int // 1-based position among the inline scripts of the page
+}
+
+// ScriptURL returns the absolute script URL, or a synthetic inline:#N URL
+// for inline scripts
+func (s pageScript) ScriptURL(baseURL string) string {
+	if s.Inline {
+		return fmt.Sprintf("inline:#%d", s.InlineIndex)
+	}
+	return toAbsoluteURL(baseURL, s.Src)
+}
+
+// collectPageScripts returns all external and inline scripts of a document
+// in the order they appear
+func collectPageScripts(doc *html.Node) []pageScript {
+	var scripts []pageScript
+	inlineCount := 0
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "script" {
+			hasSrc := false
+			scriptType := ""
 			for _, a := range n.Attr {
-				if a.Key == "src" {
-					scripts = append(scripts, a.Val)
+				switch a.Key {
+				case "src":
+					hasSrc = true
+					scripts = append(scripts, pageScript{Src: a.Val})
+				case "type":
+					scriptType = a.Val
+				}
+			}
+			if !hasSrc && isJavaScriptType(scriptType) {
+				var code strings.Builder
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						code.WriteString(c.Data)
+					}
+				}
+				if strings.TrimSpace(code.String()) != "" {
+					inlineCount++
+					scripts = append(scripts, pageScript{Code: code.String(), Inline: true, InlineIndex: inlineCount})
 				}
 			}
 		}
@@ -370,57 +642,77 @@ func scanURL(baseURL string, useDB bool, verbose bool) {
 		}
 	}
 	f(doc)
+	return scripts
+}
 
-	scriptsFound := 0
-	for _, scriptURL := range scripts {
-		fullScriptURL := toAbsoluteURL(baseURL, scriptURL)
-		logger.Printf("Processing script %s\n", fullScriptURL)
-		checksum, jsCode, err := getScriptChecksumAndContent(fullScriptURL)
+// isJavaScriptType reports whether a <script type> value denotes executable
+// JavaScript (as opposed to JSON-LD, templates, etc.)
+func isJavaScriptType(scriptType string) bool {
+	scriptType = strings.ToLower(strings.TrimSpace(scriptType))
+	if idx := strings.Index(scriptType, ";"); idx != -1 {
+		scriptType = strings.TrimSpace(scriptType[:idx])
+	}
+	switch scriptType {
+	case "", "module", "text/javascript", "application/javascript", "text/ecmascript",
+		"application/ecmascript", "application/x-javascript", "text/jscript":
+		return true
+	}
+	return false
+}
+
+// inspectScript fetches (or, for inline scripts, hashes) a single script and
+// identifies the library it contains. Inline scripts are only reported when
+// code analysis recognises a library, so a nil result with a nil error means
+// there was nothing to record.
+func inspectScript(baseURL string, script pageScript) (*ScanResult, error) {
+	fullScriptURL := script.ScriptURL(baseURL)
+	logger.Printf("Processing script %s on %s\n", fullScriptURL, baseURL)
+
+	var checksum string
+	var libraryInfo *LibraryInfo
+	if script.Inline {
+		hash := sha256.Sum256([]byte(script.Code))
+		checksum = hex.EncodeToString(hash[:])
+		if identificationSources[SourceCodeAnalysis] {
+			libraryInfo = identifyLibraryFromCode(script.Code, "")
+		}
+		if libraryInfo == nil {
+			logger.Printf("No library identified in inline script %s on %s\n", fullScriptURL, baseURL)
+			return nil, nil
+		}
+		libraryInfo.Checksum = checksum
+	} else {
+		var jsCode string
+		var err error
+		checksum, jsCode, err = getScriptChecksumAndContent(fullScriptURL)
 		if err != nil {
 			logger.Printf("Error processing script %s: %v\n", fullScriptURL, err)
-			if verbose {
-				fmt.Printf("Error processing script %s: %v\n", fullScriptURL, err)
-			}
-			continue
-		}
-		scriptsFound++
-		logger.Printf("Found script: %s, Checksum: %s\n", fullScriptURL, checksum)
-		
-		if verbose {
-			fmt.Printf("  - Found script: %s, Checksum: %s\n", fullScriptURL, checksum)
+			return nil, err
 		}
+		libraryInfo = identifyLibrary(fullScriptURL, checksum, jsCode)
+	}
 
-		libraryInfo := identifyLibrary(fullScriptURL, checksum, jsCode)
-		if libraryInfo != nil {
-			logger.Printf("Identified library for %s as: %s v%s (%s) [checksum: %s]\n", fullScriptURL, libraryInfo.Name, libraryInfo.Version, libraryInfo.Method, libraryInfo.Checksum)
-			if verbose {
-				if libraryInfo.Version != "unknown" && libraryInfo.Version != "" {
-					fmt.Printf("    Library: %s v%s (%s) [%s...]\n", libraryInfo.Name, libraryInfo.Version, libraryInfo.Method, libraryInfo.Checksum[:8])
-				} else {
-					fmt.Printf("    Library: %s (%s) [%s...]\n", libraryInfo.Name, libraryInfo.Method, libraryInfo.Checksum[:8])
-				}
-			}
-		}
+	logger.Printf("Found script: %s, Checksum: %s\n", fullScriptURL, checksum)
+	logger.Printf("Identified library for %s as: %s v%s (%s) [checksum: %s]\n",
+		fullScriptURL, libraryInfo.Name, libraryInfo.Version, libraryInfo.Method, libraryInfo.Checksum)
 
-		if useDB && libraryInfo != nil {
-			result := ScanResult{
-				URL:              baseURL,
-				ScriptURL:        fullScriptURL,
-				Checksum:         checksum,
-				LibraryName:      libraryInfo.Name,
-				LibraryVersion:   libraryInfo.Version,
-				IdentifiedBy:     libraryInfo.Method,
-			}
-			if err := storeResult(result); err != nil {
-				logger.Printf("Error storing result for %s: %v\n", fullScriptURL, err)
-			}
-		}
-	}
-	
-	// Show summary for non-verbose mode
-	if !verbose {
-		fmt.Printf(" → %d scripts found", scriptsFound)
+	vulnerabilities := vulnDB.Match(libraryInfo.Name, libraryInfo.Version)
+	if len(vulnerabilities) > 0 {
+		logger.Printf("Vulnerable library %s v%s in %s: %s\n",
+			libraryInfo.Name, libraryInfo.Version, fullScriptURL, formatVulnerabilities(vulnerabilities))
 	}
+
+	return &ScanResult{
+		URL:             baseURL,
+		ScriptURL:       fullScriptURL,
+		Checksum:        checksum,
+		LibraryName:     libraryInfo.Name,
+		LibraryVersion:  libraryInfo.Version,
+		IdentifiedBy:    libraryInfo.Method,
+		IsInline:        script.Inline,
+		Vulnerabilities: vulnerabilities,
+		ScannedAt:       time.Now(),
+	}, nil
 }
 
 func toAbsoluteURL(base, href string) string {
@@ -437,12 +729,50 @@ func toAbsoluteURL(base, href string) string {
 
 func getScriptChecksumAndContent(scriptURL string) (string, string, error) {
 	logger.Printf("Getting checksum and content for %s\n", scriptURL)
-	resp, err := http.Get(scriptURL)
+	req, err := http.NewRequest(http.MethodGet, scriptURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	// A cached copy is used as is while fresh, and revalidated instead of
+	// downloaded again afterwards
+	var cached *scriptCacheEntry
+	if scripts != nil {
+		cached = scripts.Get(scriptURL)
+	}
+	if cached != nil && cached.Fresh() {
+		logger.Printf("Script %s fresh in cache, not requested\n", scriptURL)
+		return cached.Checksum, cached.Content, nil
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	release := acquireScriptFetch()
+	defer release()
+
+	client := &http.Client{Timeout: scriptTimeout}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", "", err
 	}
 	defer resp.Body.Close()
 
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		logger.Printf("Script %s unchanged, using cached copy\n", scriptURL)
+		if freshUntil, storable := scriptFreshUntil(resp.Header, time.Now()); storable && !freshUntil.IsZero() {
+			refreshed := *cached
+			refreshed.FreshUntil = freshUntil
+			scripts.Put(&refreshed)
+		}
+		return cached.Checksum, cached.Content, nil
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Printf("Error reading script body from %s: %v\n", scriptURL, err)
@@ -453,41 +783,77 @@ func getScriptChecksumAndContent(scriptURL string) (string, string, error) {
 	checksum := hex.EncodeToString(hash[:])
 	content := string(body)
 	
-	return checksum, content, nil
-}
-
-func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	// Scripts are cached if they can be used for a while or revalidated
+	if scripts != nil && resp.StatusCode == http.StatusOK {
+		entry := &scriptCacheEntry{
+			URL:          scriptURL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Checksum:     checksum,
+			Content:      content,
+		}
+		freshUntil, storable := scriptFreshUntil(resp.Header, time.Now())
+		entry.FreshUntil = freshUntil
+		if storable && (entry.Revalidatable() || entry.Fresh()) {
+			scripts.Put(entry)
+		}
 	}
-	return lines, scanner.Err()
+	
+	return checksum, content, nil
 }
 
 func printHelp() {
 	fmt.Println("Usage: netweather [options] <url_file>")
 	fmt.Println("       netweather -stats [db-options]")
+	fmt.Println("       netweather migrate [db-options] [up|status]")
+	fmt.Println("       netweather diff [db-options] [-format text|json] <runA> <runB>")
+	fmt.Println("       netweather export [db-options] [-run id] [-output-file file] csv")
+	fmt.Println("       netweather report -format html [db-options] [-run id] [-output-file file] [results.json]")
+	fmt.Println("       netweather serve [-listen addr] [-db db-options] [-workers n]")
+	fmt.Println("       netweather daemon [db-options] [-listen addr] <schedule.yaml>")
+	fmt.Println("       netweather config validate [netweather.yaml]")
 	fmt.Println("Options:")
 	fmt.Println("  -db              Activate database storage")
+	fmt.Println("  -db-driver       Database driver: mysql, postgres or sqlite (default: mysql, env: DB_DRIVER)")
+	fmt.Println("  -db-path         Database file for sqlite (default: netweather.db, env: DB_PATH)")
 	fmt.Println("  -db-host         Database host (default: 127.0.0.1, env: DB_HOST)")
-	fmt.Println("  -db-port         Database port (default: 3306, env: DB_PORT)")
+	fmt.Println("  -db-port         Database port (default: 3306, 5432 for postgres, env: DB_PORT)")
 	fmt.Println("  -db-user         Database user (env: DB_USER)")
 	fmt.Println("  -db-password     Database password (env: DB_PASSWORD)")
 	fmt.Println("  -db-name         Database name (env: DB_NAME)")
+	fmt.Println("  -db-sslmode      SSL mode for postgres (default: disable, env: DB_SSLMODE)")
 	fmt.Println("  -stats           Show statistics of scanned URLs")
+	fmt.Println("  -run             Limit -stats and export to a single scan run ID")
+	fmt.Println("  -format          Output format: text or json for diff, html for report (default: text)")
 	fmt.Println("  -port-scan       Enable port scanning with nmap")
 	fmt.Println("  -scan-ports      Ports to scan (default: 80,443,8080,8443)")
 	fmt.Println("  -nmap-options    Additional nmap options")
+	fmt.Println("  -port-scan-workers  nmap batches running at once in parallel mode (default: 2)")
+	fmt.Println("  -port-scan-batch    Hosts grouped into one nmap batch in parallel mode (default: 25)")
 	fmt.Println("  -remote-db       Use remote entries.db from GitHub")
 	fmt.Println("  -verbose         Enable verbose output (default: false)")
-	fmt.Println("  <url_file>       File containing a list of URLs to scan.")
+	fmt.Println("  -vuln-db         Advisory file in retire.js format (default: jsrepository.json, env: VULN_DB)")
+	fmt.Println("  -sbom            Write an SBOM per scanned site: cyclonedx (1.5) or spdx (2.3)")
+	fmt.Println("  -sbom-dir        Directory for SBOM files (default: sbom)")
+	fmt.Println("  -output          Write one record per URL as json (array) or ndjson, one row per script as csv,")
+	fmt.Println("                   or a sarif (2.1.0) log of library findings; progress moves to stderr")
+	fmt.Println("  -output-file     File for -output records and exports (default: stdout, report: netweather-report.html)")
+	fmt.Println("  -listen          Address of the serve API or daemon health endpoint (default: 127.0.0.1:8090, env: LISTEN_ADDR)")
+	fmt.Println("  -config          YAML configuration file (default: netweather.yaml if present, env: NETWEATHER_CONFIG)")
+	fmt.Println("  -profile         Configuration profile to apply (default: default_profile, env: NETWEATHER_PROFILE)")
+	fmt.Println("                   Precedence: flags > environment > configuration file > defaults")
+	fmt.Println("  -rules           YAML file with include/exclude rules by host, suffix, glob, regex, cidr or path")
+	fmt.Println("  -discover-pages  Add up to N pages per host from robots.txt sitemaps and /sitemap.xml,")
+	fmt.Println("                   honoring robots.txt disallow rules (default: 0, disabled)")
+	fmt.Println("  -crawl-depth     Follow same-origin <a href> links up to N levels from each page (default: 0, disabled)")
+	fmt.Println("  -crawl-pages     Page budget per site when crawling, including the first page (default: 20)")
+	fmt.Println("  -batch-size      Rows per multi-row database INSERT in parallel mode (default: 50)")
+	fmt.Println("  -script-workers  Scripts of a page fetched concurrently (default: 4)")
+	fmt.Println("  -script-fetches  Script downloads in flight across all pages and workers (default: 32)")
+	fmt.Println("  -script-cache-mb Memory for scripts shared between sites, used while fresh, then revalidated (default: 64, 0 disables)")
+	fmt.Println("  -script-cache-dir Directory keeping downloaded scripts between runs (default: none)")
+	fmt.Println("  <url_file>       File containing a list of URLs to scan, or - for stdin: one URL per line,")
+	fmt.Println("                   CSV with url, owner and tags columns, or a JSON array (# comments are skipped)")
 	fmt.Println()
 	fmt.Println("Features:")
 	fmt.Println("  - Checks URL reachability via HTTP and HTTPS")
@@ -496,7 +862,93 @@ func printHelp() {
 	fmt.Println("  - Handles URLs without protocol prefix (tests both HTTP/HTTPS)")
 	fmt.Println("  - Skips JavaScript scanning for non-200 responses")
 	fmt.Println("  - Clean, progress-based output in non-verbose mode")
-	fmt.Println("  - Excludes sensitive domains (e.g., Microsoft login URLs)")
+	fmt.Println("  - Excludes sensitive domains (e.g., Microsoft login URLs) and URLs matching -rules")
+	fmt.Println("  - Matches identified library versions against known vulnerabilities")
+}
+
+// commands lists the subcommands, given as the first argument that is not an
+// option
+var commands = map[string]bool{
+	"migrate": true,
+	"diff":    true,
+	"export":  true,
+	"report":  true,
+	"serve":   true,
+	"daemon":  true,
+	"config":  true,
+}
+
+// commandNeedsDB reports whether a subcommand reads from the database
+func commandNeedsDB(command string, args []string) bool {
+	switch command {
+	case "migrate", "diff", "export", "daemon":
+		return true
+	case "report":
+		// A report from a results file needs no database
+		return len(args) == 0
+	}
+	return false
+}
+
+// splitCommand separates the subcommand from the remaining arguments. The
+// subcommand is the first argument that is neither an option nor the value
+// of one, so options of flags may also precede it:
+// netweather -db-driver sqlite diff 1 2
+func splitCommand(flags *flag.FlagSet, args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			if !commands[arg] {
+				break
+			}
+			rest := append(append([]string{}, args[:i]...), args[i+1:]...)
+			return arg, rest
+		}
+		// Skip the value of an option given as a separate argument
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := flags.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				continue
+			}
+			i++
+		}
+	}
+	return "", args
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments and returns the positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// explicitFlags returns the command line flags that were set explicitly,
+// with secrets masked, for recording alongside a scan run
+func explicitFlags() string {
+	var set []string
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if f.Name == "db-password" {
+			value = "***"
+		}
+		set = append(set, fmt.Sprintf("-%s=%s", f.Name, value))
+	})
+	return strings.Join(set, " ")
 }
 
 // getConfigValue returns the first non-empty value from command line, environment, or default
@@ -510,12 +962,22 @@ func getConfigValue(cmdValue, envKey, defaultValue string) string {
 	return defaultValue
 }
 
-// showStatistics displays statistics from the database
-func showStatistics() {
-	fmt.Println("\n=== NetWeather Statistics ===\n")
+// showStatistics displays statistics from the database, limited to a single
+// scan run unless runID is 0
+func showStatistics(runID int64) {
+	fmt.Print("\n=== NetWeather Statistics ===\n\n")
+	
+	if runID != 0 {
+		run, err := store.ScanRun(runID)
+		if err != nil {
+			fmt.Printf("Error retrieving scan run %d: %v\n", runID, err)
+			return
+		}
+		fmt.Printf("Scan run %d: %s\n\n", run.ID, describeScanRun(run))
+	}
 	
 	// Get overall statistics
-	stats, err := getOverallStatistics()
+	stats, err := getOverallStatistics(runID)
 	if err != nil {
 		fmt.Printf("Error retrieving statistics: %v\n", err)
 		return
@@ -532,9 +994,25 @@ func showStatistics() {
 		fmt.Printf("Last scan: %s\n", stats.LastScan.Format("2006-01-02 15:04:05"))
 	}
 	
+	// List recent scan runs so they can be selected with -run
+	if runID == 0 {
+		fmt.Println("\n=== Scan Runs ===")
+		runs, err := getScanRuns(10)
+		if err != nil {
+			fmt.Printf("Error retrieving scan runs: %v\n", err)
+		} else if len(runs) == 0 {
+			fmt.Println("No scan runs recorded.")
+		} else {
+			fmt.Println()
+			for i := range runs {
+				fmt.Printf("#%-5d %s\n", runs[i].ID, describeScanRun(&runs[i]))
+			}
+		}
+	}
+	
 	// Get library usage statistics
 	fmt.Println("\n=== Library Usage ===")
-	libraries, err := getLibraryStatistics()
+	libraries, err := getLibraryStatistics(runID)
 	if err != nil {
 		fmt.Printf("Error retrieving library statistics: %v\n", err)
 		return
@@ -559,9 +1037,30 @@ func showStatistics() {
 		}
 	}
 	
+	// Get vulnerable libraries
+	fmt.Println("\n=== Vulnerable Libraries ===")
+	vulnerable, err := getVulnerabilityStatistics(runID)
+	if err != nil {
+		fmt.Printf("Error retrieving vulnerability statistics: %v\n", err)
+	} else if len(vulnerable) == 0 {
+		fmt.Println("No vulnerable libraries found.")
+	} else {
+		fmt.Println()
+		for _, lib := range vulnerable {
+			line := fmt.Sprintf("%-25s v%-8s %s", lib.Name, lib.Version, strings.ReplaceAll(lib.CVEIDs, ",", ", "))
+			if lib.Severity != "" {
+				line += fmt.Sprintf(" (%s)", lib.Severity)
+			}
+			if lib.FixedIn != "" {
+				line += fmt.Sprintf(", fixed in %s", lib.FixedIn)
+			}
+			fmt.Printf("%s: %d sites\n", line, lib.Sites)
+		}
+	}
+	
 	// Get recent scans
 	fmt.Println("\n=== Recent Scans ===")
-	recentURLs, err := getRecentScans(10)
+	recentURLs, err := getRecentScans(10, runID)
 	if err != nil {
 		fmt.Printf("Error retrieving recent scans: %v\n", err)
 		return
@@ -579,7 +1078,7 @@ func showStatistics() {
 	
 	// Get URL reachability statistics
 	fmt.Println("\n=== URL Reachability ===")
-	reachStats, err := getURLReachabilityStatistics()
+	reachStats, err := getURLReachabilityStatistics(runID)
 	if err != nil {
 		fmt.Printf("Error retrieving reachability statistics: %v\n", err)
 	} else if reachStats.TotalChecked > 0 {
@@ -596,7 +1095,7 @@ func showStatistics() {
 	
 	// Get nmap batch statistics
 	fmt.Println("\n=== Port Scan Batches ===")
-	nmapStats, err := getNmapBatchStatistics()
+	nmapStats, err := getNmapBatchStatistics(runID)
 	if err != nil {
 		fmt.Printf("Error retrieving batch statistics: %v\n", err)
 		return
@@ -611,4 +1110,35 @@ func showStatistics() {
 	for status, count := range nmapStats {
 		fmt.Printf("%-15s: %d batches\n", status, count)
 	}
+	
+	// Get open port statistics
+	fmt.Println("\n=== Open Ports ===")
+	portStats, err := getPortStatistics(runID)
+	if err != nil {
+		fmt.Printf("Error retrieving port statistics: %v\n", err)
+		return
+	}
+	if portStats.HostsScanned == 0 {
+		fmt.Println("No port scan results found.")
+		return
+	}
+	
+	fmt.Println()
+	fmt.Printf("Hosts scanned: %d\n", portStats.HostsScanned)
+	fmt.Printf("Hosts with open ports: %d\n", portStats.HostsWithOpenPorts)
+	for _, port := range portStats.Ports {
+		fmt.Printf("%6d/%-4s %-15s: %d hosts\n", port.Port, port.Protocol, port.Service, port.Hosts)
+	}
+}
+
+// describeScanRun summarizes a scan run on a single line
+func describeScanRun(run *ScanRun) string {
+	timing := run.StartedAt.Format("2006-01-02 15:04:05")
+	if run.FinishedAt != nil {
+		timing += fmt.Sprintf(" (%s)", run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+	} else {
+		timing += " (unfinished)"
+	}
+	return fmt.Sprintf("%s, %s, %d workers: %d/%d processed, %d scanned, %d excluded, %d skipped, %d errors",
+		timing, run.InputFile, run.Workers, run.Processed, run.TotalURLs, run.Scanned, run.Excluded, run.Skipped, run.Errors)
 }
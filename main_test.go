@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+func TestSplitCommand(t *testing.T) {
+	flags := flag.NewFlagSet("netweather", flag.ContinueOnError)
+	flags.String("db-driver", "", "")
+	flags.String("format", "", "")
+	flags.Bool("verbose", false, "")
+
+	tests := []struct {
+		args        []string
+		wantCommand string
+		wantArgs    []string
+	}{
+		{[]string{"diff", "1", "2"}, "diff", []string{"1", "2"}},
+		{[]string{"-db-driver", "sqlite", "diff", "1", "2"}, "diff", []string{"-db-driver", "sqlite", "1", "2"}},
+		{[]string{"-db-driver=sqlite", "-verbose", "export", "csv"}, "export", []string{"-db-driver=sqlite", "-verbose", "csv"}},
+		{[]string{"--format", "html", "report"}, "report", []string{"--format", "html"}},
+		{[]string{"-db-driver", "diff", "urls.txt"}, "", []string{"-db-driver", "diff", "urls.txt"}},
+		{[]string{"-verbose", "urls.txt"}, "", []string{"-verbose", "urls.txt"}},
+		{[]string{"urls.txt", "diff"}, "", []string{"urls.txt", "diff"}},
+		{[]string{"--", "diff"}, "", []string{"--", "diff"}},
+		{nil, "", nil},
+	}
+	for _, tt := range tests {
+		command, args := splitCommand(flags, tt.args)
+		if command != tt.wantCommand || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("splitCommand(%q) = %q, %q, want %q, %q", tt.args, command, args, tt.wantCommand, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the up-migrations of every dialect, stored as
+// migrations/<dialect>/NNNN_description.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// MigrationStatus reports whether a migration has been applied to a database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// schemaMigrationsTable records applied migrations; the DDL is portable
+// across all supported dialects
+const schemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// loadMigrations returns the embedded migrations of a dialect ordered by version
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %v", dialect, err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s (expected NNNN_description.sql)", entry.Name())
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Version:    version,
+			Name:       name,
+			Statements: splitSQLStatements(string(data)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitSQLStatements splits a migration file into statements terminated by a
+// semicolon at the end of a line. Statements that contain semicolons of their
+// own (function bodies, triggers) are wrapped in "-- +begin" / "-- +end".
+func splitSQLStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inBlock := false
+
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		statement = strings.TrimSuffix(statement, ";")
+		if strings.TrimSpace(statement) != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "-- +begin":
+			flush()
+			inBlock = true
+			continue
+		case trimmed == "-- +end":
+			flush()
+			inBlock = false
+			continue
+		case strings.HasPrefix(trimmed, "--") || trimmed == "":
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+	return statements
+}
+
+// runMigrateCommand implements "netweather migrate [up|status]" and returns
+// the process exit code
+func runMigrateCommand(args []string) int {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		count, err := store.Migrate()
+		if err != nil {
+			fmt.Printf("Migration failed: %v\n", err)
+			return 1
+		}
+		if count == 0 {
+			fmt.Println("Database schema is up to date.")
+		} else {
+			fmt.Printf("Applied %d migration(s).\n", count)
+		}
+		return 0
+
+	case "status":
+		statuses, err := store.MigrationStatus()
+		if err != nil {
+			fmt.Printf("Error retrieving migration status: %v\n", err)
+			return 1
+		}
+		pending := 0
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+				if status.AppliedAt != nil {
+					state += " " + status.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+			} else {
+				pending++
+			}
+			fmt.Printf("%04d %-30s %s\n", status.Version, status.Name, state)
+		}
+		fmt.Printf("\n%d of %d migrations pending.\n", pending, len(statuses))
+		return 0
+
+	default:
+		fmt.Printf("Unknown migrate action %q (use up or status)\n", action)
+		return 1
+	}
+}
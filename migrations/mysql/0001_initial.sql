@@ -0,0 +1,43 @@
+-- Base tables. scan_results matches scripts/create_tables.sql; columns added
+-- since then are introduced by the following migrations.
+CREATE TABLE IF NOT EXISTS scan_results (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2083) NOT NULL,
+    script_url VARCHAR(2083) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    library_name VARCHAR(255),
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    date DATE,
+    INDEX idx_library (library_name),
+    INDEX idx_checksum (checksum)
+);
+
+-- Track port scan batches
+CREATE TABLE IF NOT EXISTS nmap_batches (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL UNIQUE,
+    url VARCHAR(2083) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    ports TEXT,
+    results TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_batch_id (batch_id),
+    INDEX idx_status (status)
+);
+
+CREATE TABLE IF NOT EXISTS url_reachability (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    original_url VARCHAR(2083) NOT NULL,
+    http_available BOOLEAN DEFAULT FALSE,
+    https_available BOOLEAN DEFAULT FALSE,
+    http_status_code INT,
+    https_status_code INT,
+    http_redirect_url VARCHAR(2083),
+    https_redirect_url VARCHAR(2083),
+    final_url VARCHAR(2083),
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_original_url (original_url),
+    INDEX idx_scanned_at (scanned_at),
+    INDEX idx_availability (http_available, https_available)
+);
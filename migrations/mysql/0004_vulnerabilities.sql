@@ -0,0 +1,4 @@
+-- Advisories matched against the identified library version
+ALTER TABLE scan_results ADD COLUMN cve_ids TEXT;
+ALTER TABLE scan_results ADD COLUMN severity VARCHAR(20);
+ALTER TABLE scan_results ADD COLUMN fixed_in VARCHAR(100);
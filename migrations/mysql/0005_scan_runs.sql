@@ -0,0 +1,22 @@
+-- Every invocation of the scanner is recorded as a scan run
+CREATE TABLE IF NOT EXISTS scan_runs (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    started_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL,
+    input_file VARCHAR(1024),
+    flags TEXT,
+    workers INT,
+    total_urls INT DEFAULT 0,
+    processed INT DEFAULT 0,
+    scanned INT DEFAULT 0,
+    excluded INT DEFAULT 0,
+    skipped INT DEFAULT 0,
+    errors INT DEFAULT 0
+);
+
+ALTER TABLE scan_results ADD COLUMN run_id INT;
+ALTER TABLE scan_results ADD CONSTRAINT fk_scan_results_run FOREIGN KEY (run_id) REFERENCES scan_runs (id);
+ALTER TABLE url_reachability ADD COLUMN run_id INT;
+ALTER TABLE url_reachability ADD CONSTRAINT fk_url_reachability_run FOREIGN KEY (run_id) REFERENCES scan_runs (id);
+ALTER TABLE nmap_batches ADD COLUMN run_id INT;
+ALTER TABLE nmap_batches ADD CONSTRAINT fk_nmap_batches_run FOREIGN KEY (run_id) REFERENCES scan_runs (id);
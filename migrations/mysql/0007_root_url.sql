@@ -0,0 +1,2 @@
+-- Site a crawled or discovered page belongs to, NULL when the page is the scanned URL itself
+ALTER TABLE scan_results ADD COLUMN root_url VARCHAR(2083);
@@ -0,0 +1,28 @@
+-- Hosts found by an nmap batch, one row per scanned URL the host belongs to
+CREATE TABLE IF NOT EXISTS nmap_hosts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL,
+    url VARCHAR(2083) NOT NULL,
+    ip VARCHAR(45),
+    hostname VARCHAR(255),
+    run_id INT,
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_nmap_hosts_batch (batch_id),
+    INDEX idx_nmap_hosts_run (run_id),
+    CONSTRAINT fk_nmap_hosts_batch FOREIGN KEY (batch_id) REFERENCES nmap_batches (batch_id),
+    CONSTRAINT fk_nmap_hosts_run FOREIGN KEY (run_id) REFERENCES scan_runs (id)
+);
+
+-- Open ports of a host
+CREATE TABLE IF NOT EXISTS nmap_ports (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    host_id INT NOT NULL,
+    port INT NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    service VARCHAR(255),
+    product VARCHAR(255),
+    version VARCHAR(255),
+    INDEX idx_nmap_ports_port (port, protocol),
+    CONSTRAINT fk_nmap_ports_host FOREIGN KEY (host_id) REFERENCES nmap_hosts (id)
+);
@@ -0,0 +1,56 @@
+-- Base tables. scan_results matches scripts/create_tables.sql; columns added
+-- since then are introduced by the following migrations.
+CREATE TABLE IF NOT EXISTS scan_results (
+    id BIGSERIAL PRIMARY KEY,
+    url VARCHAR(2083) NOT NULL,
+    script_url VARCHAR(2083) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    library_name VARCHAR(255),
+    scanned_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    date DATE
+);
+CREATE INDEX IF NOT EXISTS idx_scan_results_library ON scan_results (library_name);
+CREATE INDEX IF NOT EXISTS idx_scan_results_checksum ON scan_results (checksum);
+
+-- Track port scan batches
+CREATE TABLE IF NOT EXISTS nmap_batches (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL UNIQUE,
+    url VARCHAR(2083) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    ports TEXT,
+    results TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_batches_status ON nmap_batches (status);
+
+-- PostgreSQL has no ON UPDATE clause, keep updated_at current with a trigger
+-- +begin
+CREATE OR REPLACE FUNCTION netweather_set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +end
+DROP TRIGGER IF EXISTS trg_nmap_batches_updated_at ON nmap_batches;
+CREATE TRIGGER trg_nmap_batches_updated_at
+    BEFORE UPDATE ON nmap_batches FOR EACH ROW
+    EXECUTE FUNCTION netweather_set_updated_at();
+
+CREATE TABLE IF NOT EXISTS url_reachability (
+    id BIGSERIAL PRIMARY KEY,
+    original_url VARCHAR(2083) NOT NULL,
+    http_available BOOLEAN DEFAULT FALSE,
+    https_available BOOLEAN DEFAULT FALSE,
+    http_status_code INTEGER,
+    https_status_code INTEGER,
+    http_redirect_url VARCHAR(2083),
+    https_redirect_url VARCHAR(2083),
+    final_url VARCHAR(2083),
+    scanned_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_original_url ON url_reachability (original_url);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_scanned_at ON url_reachability (scanned_at);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_availability ON url_reachability (http_available, https_available);
@@ -0,0 +1,3 @@
+-- Library version and identification method
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS library_version VARCHAR(100);
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS identified_by VARCHAR(50);
@@ -0,0 +1,2 @@
+-- Flag for scripts embedded in the page (ScriptURL is inline:#N)
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS is_inline BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,26 @@
+-- Hosts found by an nmap batch, one row per scanned URL the host belongs to
+CREATE TABLE IF NOT EXISTS nmap_hosts (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id VARCHAR(255) NOT NULL REFERENCES nmap_batches (batch_id),
+    url VARCHAR(2083) NOT NULL,
+    ip VARCHAR(45),
+    hostname VARCHAR(255),
+    run_id BIGINT REFERENCES scan_runs (id),
+    scanned_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_hosts_batch ON nmap_hosts (batch_id);
+CREATE INDEX IF NOT EXISTS idx_nmap_hosts_run ON nmap_hosts (run_id);
+
+-- Open ports of a host
+CREATE TABLE IF NOT EXISTS nmap_ports (
+    id BIGSERIAL PRIMARY KEY,
+    host_id BIGINT NOT NULL REFERENCES nmap_hosts (id),
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    service VARCHAR(255),
+    product VARCHAR(255),
+    version VARCHAR(255)
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_ports_host ON nmap_ports (host_id);
+CREATE INDEX IF NOT EXISTS idx_nmap_ports_port ON nmap_ports (port, protocol);
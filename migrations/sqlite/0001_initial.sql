@@ -0,0 +1,51 @@
+-- Base tables. scan_results matches scripts/create_tables.sql; columns added
+-- since then are introduced by the following migrations.
+CREATE TABLE IF NOT EXISTS scan_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    script_url TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    library_name TEXT,
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    date DATE
+);
+CREATE INDEX IF NOT EXISTS idx_scan_results_library ON scan_results (library_name);
+CREATE INDEX IF NOT EXISTS idx_scan_results_checksum ON scan_results (checksum);
+
+-- Track port scan batches
+CREATE TABLE IF NOT EXISTS nmap_batches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    status TEXT NOT NULL,
+    ports TEXT,
+    results TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_batches_status ON nmap_batches (status);
+
+-- SQLite has no ON UPDATE clause, keep updated_at current with a trigger
+-- +begin
+CREATE TRIGGER IF NOT EXISTS trg_nmap_batches_updated_at
+    AFTER UPDATE ON nmap_batches FOR EACH ROW
+    BEGIN
+        UPDATE nmap_batches SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+    END;
+-- +end
+
+CREATE TABLE IF NOT EXISTS url_reachability (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_url TEXT NOT NULL,
+    http_available BOOLEAN DEFAULT FALSE,
+    https_available BOOLEAN DEFAULT FALSE,
+    http_status_code INTEGER,
+    https_status_code INTEGER,
+    http_redirect_url TEXT,
+    https_redirect_url TEXT,
+    final_url TEXT,
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_original_url ON url_reachability (original_url);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_scanned_at ON url_reachability (scanned_at);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_availability ON url_reachability (http_available, https_available);
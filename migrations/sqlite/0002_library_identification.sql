@@ -0,0 +1,3 @@
+-- Library version and identification method
+ALTER TABLE scan_results ADD COLUMN library_version TEXT;
+ALTER TABLE scan_results ADD COLUMN identified_by TEXT;
@@ -0,0 +1,22 @@
+-- Every invocation of the scanner is recorded as a scan run
+CREATE TABLE IF NOT EXISTS scan_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    input_file TEXT,
+    flags TEXT,
+    workers INTEGER,
+    total_urls INTEGER DEFAULT 0,
+    processed INTEGER DEFAULT 0,
+    scanned INTEGER DEFAULT 0,
+    excluded INTEGER DEFAULT 0,
+    skipped INTEGER DEFAULT 0,
+    errors INTEGER DEFAULT 0
+);
+
+ALTER TABLE scan_results ADD COLUMN run_id INTEGER REFERENCES scan_runs (id);
+CREATE INDEX IF NOT EXISTS idx_scan_results_run ON scan_results (run_id);
+ALTER TABLE url_reachability ADD COLUMN run_id INTEGER REFERENCES scan_runs (id);
+CREATE INDEX IF NOT EXISTS idx_url_reachability_run ON url_reachability (run_id);
+ALTER TABLE nmap_batches ADD COLUMN run_id INTEGER REFERENCES scan_runs (id);
+CREATE INDEX IF NOT EXISTS idx_nmap_batches_run ON nmap_batches (run_id);
@@ -0,0 +1,3 @@
+-- Owner team and tags of the scanned site from the input list (tags are comma-separated)
+ALTER TABLE scan_results ADD COLUMN owner TEXT;
+ALTER TABLE scan_results ADD COLUMN tags TEXT;
@@ -0,0 +1,26 @@
+-- Hosts found by an nmap batch, one row per scanned URL the host belongs to
+CREATE TABLE IF NOT EXISTS nmap_hosts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    batch_id TEXT NOT NULL REFERENCES nmap_batches (batch_id),
+    url TEXT NOT NULL,
+    ip TEXT,
+    hostname TEXT,
+    run_id INTEGER REFERENCES scan_runs (id),
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_hosts_batch ON nmap_hosts (batch_id);
+CREATE INDEX IF NOT EXISTS idx_nmap_hosts_run ON nmap_hosts (run_id);
+
+-- Open ports of a host
+CREATE TABLE IF NOT EXISTS nmap_ports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    host_id INTEGER NOT NULL REFERENCES nmap_hosts (id),
+    port INTEGER NOT NULL,
+    protocol TEXT NOT NULL,
+    state TEXT NOT NULL,
+    service TEXT,
+    product TEXT,
+    version TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_nmap_ports_host ON nmap_ports (host_id);
+CREATE INDEX IF NOT EXISTS idx_nmap_ports_port ON nmap_ports (port, protocol);
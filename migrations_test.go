@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			script: "",
+		},
+		{
+			name:   "comments and blank lines only",
+			script: "-- nothing to do\n\n   -- really\n",
+		},
+		{
+			name:   "one statement per line",
+			script: "CREATE TABLE a (id INTEGER);\nCREATE INDEX idx_a ON a (id);\n",
+			want:   []string{"CREATE TABLE a (id INTEGER)", "CREATE INDEX idx_a ON a (id)"},
+		},
+		{
+			name:   "statement over several lines with comments",
+			script: "-- runs\nCREATE TABLE runs (\n    id INTEGER, -- key\n    -- the name\n    name TEXT\n);\n",
+			want:   []string{"CREATE TABLE runs (\n    id INTEGER, -- key\n    name TEXT\n)"},
+		},
+		{
+			name:   "semicolon inside a line does not split",
+			script: "INSERT INTO t VALUES ('a;b');\n",
+			want:   []string{"INSERT INTO t VALUES ('a;b')"},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "ALTER TABLE a ADD COLUMN b TEXT;\nALTER TABLE a ADD COLUMN c TEXT",
+			want:   []string{"ALTER TABLE a ADD COLUMN b TEXT", "ALTER TABLE a ADD COLUMN c TEXT"},
+		},
+		{
+			name: "begin and end block",
+			script: "CREATE TABLE a (updated_at TIMESTAMP);\n" +
+				"-- +begin\n" +
+				"CREATE FUNCTION touch() RETURNS TRIGGER AS $$\n" +
+				"BEGIN\n" +
+				"    NEW.updated_at = CURRENT_TIMESTAMP;\n" +
+				"    RETURN NEW;\n" +
+				"END;\n" +
+				"$$ LANGUAGE plpgsql;\n" +
+				"-- +end\n" +
+				"CREATE TRIGGER trg BEFORE UPDATE ON a\n" +
+				"    FOR EACH ROW EXECUTE FUNCTION touch();\n",
+			want: []string{
+				"CREATE TABLE a (updated_at TIMESTAMP)",
+				"CREATE FUNCTION touch() RETURNS TRIGGER AS $$\nBEGIN\n    NEW.updated_at = CURRENT_TIMESTAMP;\n    RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+				"CREATE TRIGGER trg BEFORE UPDATE ON a\n    FOR EACH ROW EXECUTE FUNCTION touch()",
+			},
+		},
+		{
+			name:   "block markers with surrounding spaces and CRLF",
+			script: "  -- +begin  \r\nCREATE TRIGGER t AFTER INSERT ON a BEGIN\r\n  UPDATE a SET x = 1;\r\nEND;\r\n-- +end\r\n",
+			want:   []string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN\r\n  UPDATE a SET x = 1;\r\nEND"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitSQLStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{"mysql", "postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := loadMigrations(dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, m := range migrations {
+				if m.Version != i+1 {
+					t.Errorf("migration %d has version %d", i+1, m.Version)
+				}
+				if len(m.Statements) == 0 {
+					t.Errorf("migration %04d_%s has no statements", m.Version, m.Name)
+				}
+				for _, statement := range m.Statements {
+					if strings.Contains(statement, "-- +") || strings.HasSuffix(statement, ";") {
+						t.Errorf("migration %04d_%s: statement not split cleanly: %q", m.Version, m.Name, statement)
+					}
+				}
+			}
+		})
+	}
+
+	// The PostgreSQL trigger function stays one statement
+	migrations, err := loadMigrations("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, statement := range migrations[0].Statements {
+		if strings.HasPrefix(statement, "CREATE OR REPLACE FUNCTION netweather_set_updated_at()") {
+			found = strings.Contains(statement, "RETURN NEW;") && strings.HasSuffix(statement, "$$ LANGUAGE plpgsql")
+		}
+	}
+	if !found {
+		t.Error("postgres 0001_initial does not contain the complete trigger function")
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os/exec"
+	"strings"
 	"time"
 )
 
@@ -119,92 +121,141 @@ type Hostname struct {
 	Type    string   `xml:"type,attr"`
 }
 
+var (
+	nmapServiceURL = "http://localhost:8080" // Default nmap service URL, port_scan.service_url in the configuration
+)
+
+// nmapBatchTimeout bounds the wait for a batch to complete
+const nmapBatchTimeout = 5 * time.Minute
+
+// Defaults of the parallel port scan stage
 const (
-	nmapServiceURL = "http://localhost:8080" // Default nmap service URL
+	defaultPortScanWorkers   = 2
+	defaultPortScanBatchSize = 25
+	portScanBatchWait        = 10 * time.Second // Longest wait for a batch to fill
 )
 
-// performPortScan performs port scanning for a given URL
-func performPortScan(targetURL, ports, options string) {
+// PortScanConfig controls port scanning of scanned URLs in parallel mode
+type PortScanConfig struct {
+	Enabled   bool
+	Ports     string
+	Options   string
+	Workers   int // nmap batches running at once
+	BatchSize int // Hosts per nmap batch
+}
+
+// performPortScan performs port scanning for a given URL, displays the
+// results and returns them (nil if the scan could not be completed)
+func performPortScan(progress io.Writer, targetURL, ports, options string, runID int64) []NmapResult {
 	// Extract hostname/IP from URL
+	hostname := portScanHost(targetURL)
+	if hostname == "" {
+		logger.Printf("Error: No hostname found in URL %s", targetURL)
+		fmt.Fprintf(progress, "    Error: No hostname found\n")
+		return nil
+	}
+
+	if err := ensureNmapService(progress); err != nil {
+		logger.Printf("Error starting NMAP service: %v", err)
+		fmt.Fprintf(progress, "    Error: %v\n", err)
+		return nil
+	}
+
+	batchID, nmapResults, err := runNmapBatch([]string{hostname}, ports, options, targetURL, runID)
+	if err != nil {
+		logger.Printf("Port scan of %s failed: %v", hostname, err)
+		fmt.Fprintf(progress, "    Port scan failed: %v\n", err)
+		return nil
+	}
+	storeNmapResults(batchID, targetURL, runID, nmapResults)
+
+	displayNmapResults(progress, nmapResults, targetURL)
+	return nmapResults
+}
+
+// portScanHost returns the hostname or IP address of a URL, or an empty
+// string if it has none
+func portScanHost(targetURL string) string {
 	parsedURL, err := url.Parse(targetURL)
 	if err != nil {
 		logger.Printf("Error parsing URL %s: %v", targetURL, err)
-		fmt.Printf("    Error: Invalid URL format\n")
-		return
+		return ""
 	}
+	return strings.ToLower(parsedURL.Hostname())
+}
 
-	hostname := parsedURL.Hostname()
-	if hostname == "" {
-		logger.Printf("Error: No hostname found in URL %s", targetURL)
-		fmt.Printf("    Error: No hostname found\n")
-		return
-	}
-
-	// Check if Docker container is running
-	if !isNmapServiceRunning() {
-		logger.Printf("NMAP service not running, starting Docker container...")
-		fmt.Printf("    Starting NMAP scanner container...\n")
-		
-		if err := startNmapContainer(); err != nil {
-			logger.Printf("Error starting NMAP container: %v", err)
-			fmt.Printf("    Error: Failed to start NMAP container\n")
-			return
-		}
-		
-		// Wait for service to be ready
-		if !waitForNmapService(30 * time.Second) {
-			logger.Printf("NMAP service failed to start")
-			fmt.Printf("    Error: NMAP service failed to start\n")
-			return
-		}
+// ensureNmapService starts the nmap scanner container unless the service
+// is already running, and waits for it to become ready
+func ensureNmapService(progress io.Writer) error {
+	if isNmapServiceRunning() {
+		return nil
 	}
 
-	// Create scan request
-	scanReq := NmapScanRequest{
-		URLs:    []string{hostname},
-		Ports:   ports,
-		Options: options,
+	logger.Printf("NMAP service not running, starting Docker container...")
+	fmt.Fprintf(progress, "    Starting NMAP scanner container...\n")
+	if err := startNmapContainer(); err != nil {
+		return fmt.Errorf("failed to start NMAP container: %v", err)
+	}
+	if !waitForNmapService(30 * time.Second) {
+		return fmt.Errorf("NMAP service failed to start")
 	}
+	return nil
+}
 
-	// Send scan request
-	batchID, err := createNmapBatch(scanReq)
+// runNmapBatch scans the hosts in a single nmap batch, waits for it to
+// complete and returns the batch ID and parsed results. The batch is stored
+// under label, the URL or a short description of the hosts it was created
+// for, and marked completed or failed once it ends. A batch that cannot be
+// stored fails, as its hosts could not be stored either.
+func runNmapBatch(hosts []string, ports, options, label string, runID int64) (string, []NmapResult, error) {
+	batchID, err := createNmapBatch(NmapScanRequest{
+		URLs:    hosts,
+		Ports:   ports,
+		Options: options,
+	})
 	if err != nil {
-		logger.Printf("Error creating NMAP batch: %v", err)
-		fmt.Printf("    Error: Failed to create scan batch\n")
-		return
+		return "", nil, fmt.Errorf("error creating scan batch: %v", err)
 	}
 
-	logger.Printf("Created NMAP batch %s for %s", batchID, hostname)
-	fmt.Printf("    Scan batch created: %s\n", batchID)
+	logger.Printf("Created NMAP batch %s for %s", batchID, strings.Join(hosts, ", "))
 
 	// Store batch ID for later retrieval
-	storeBatchID(batchID, targetURL)
+	if err := storeBatchID(batchID, label, runID); err != nil {
+		return batchID, nil, fmt.Errorf("error storing batch %s: %v", batchID, err)
+	}
 
 	// Wait for scan completion (with timeout)
-	timeout := 5 * time.Minute
-	if err := waitForBatchCompletion(batchID, timeout); err != nil {
-		logger.Printf("Batch %s did not complete: %v", batchID, err)
-		fmt.Printf("    Scan timeout or error (batch: %s)\n", batchID)
-		return
+	if err := waitForBatchCompletion(batchID, nmapBatchTimeout); err != nil {
+		updateBatchStatus(batchID, BatchFailed, nil)
+		return batchID, nil, fmt.Errorf("batch %s did not complete: %v", batchID, err)
 	}
 
-	// Retrieve and process results
 	results, err := getNmapResults(batchID)
 	if err != nil {
-		logger.Printf("Error retrieving results for batch %s: %v", batchID, err)
-		fmt.Printf("    Error retrieving scan results\n")
-		return
+		updateBatchStatus(batchID, BatchFailed, nil)
+		return batchID, nil, fmt.Errorf("error retrieving results for batch %s: %v", batchID, err)
 	}
 
-	// Parse and display results
 	nmapResults, err := parseNmapXML(results)
 	if err != nil {
-		logger.Printf("Error parsing NMAP results: %v", err)
-		fmt.Printf("    Error parsing scan results\n")
-		return
+		updateBatchStatus(batchID, BatchFailed, results)
+		return batchID, nil, fmt.Errorf("error parsing results of batch %s: %v", batchID, err)
 	}
+	updateBatchStatus(batchID, BatchCompleted, results)
+	return batchID, nmapResults, nil
+}
 
-	displayNmapResults(nmapResults, targetURL)
+// hostResults returns the results of a batch that belong to a host: by IP
+// address for IP targets, by the hostname nmap was given otherwise
+func hostResults(results []NmapResult, host string) []NmapResult {
+	isIP := net.ParseIP(host) != nil
+	var matched []NmapResult
+	for _, result := range results {
+		if (isIP && result.IP == host) || (!isIP && strings.EqualFold(result.Hostname, host)) {
+			matched = append(matched, result)
+		}
+	}
+	return matched
 }
 
 // isNmapServiceRunning checks if the nmap service is accessible
@@ -378,9 +429,12 @@ func parseNmapXML(xmlData []byte) ([]NmapResult, error) {
 			}
 		}
 
-		// Get hostname
-		if len(host.Hostnames.Hostnames) > 0 {
-			result.Hostname = host.Hostnames.Hostnames[0].Name
+		// Get hostname, preferring the name nmap was given over
+		// names from reverse DNS
+		for _, hostname := range host.Hostnames.Hostnames {
+			if result.Hostname == "" || hostname.Type == "user" {
+				result.Hostname = hostname.Name
+			}
 		}
 
 		// Get open ports
@@ -405,20 +459,20 @@ func parseNmapXML(xmlData []byte) ([]NmapResult, error) {
 }
 
 // displayNmapResults displays the parsed nmap results
-func displayNmapResults(results []NmapResult, originalURL string) {
+func displayNmapResults(progress io.Writer, results []NmapResult, originalURL string) {
 	for _, result := range results {
-		fmt.Printf("    Port scan results for %s:\n", originalURL)
+		fmt.Fprintf(progress, "    Port scan results for %s:\n", originalURL)
 		if result.IP != "" {
-			fmt.Printf("      IP: %s\n", result.IP)
+			fmt.Fprintf(progress, "      IP: %s\n", result.IP)
 		}
 		if result.Hostname != "" {
-			fmt.Printf("      Hostname: %s\n", result.Hostname)
+			fmt.Fprintf(progress, "      Hostname: %s\n", result.Hostname)
 		}
 
 		if len(result.OpenPorts) == 0 {
-			fmt.Printf("      No open ports found\n")
+			fmt.Fprintf(progress, "      No open ports found\n")
 		} else {
-			fmt.Printf("      Open ports:\n")
+			fmt.Fprintf(progress, "      Open ports:\n")
 			for _, port := range result.OpenPorts {
 				serviceInfo := port.Service
 				if port.Product != "" {
@@ -428,21 +482,38 @@ func displayNmapResults(results []NmapResult, originalURL string) {
 					}
 					serviceInfo += ")"
 				}
-				fmt.Printf("        %s/%s - %s\n", port.Port, port.Protocol, serviceInfo)
+				fmt.Fprintf(progress, "        %s/%s - %s\n", port.Port, port.Protocol, serviceInfo)
 			}
 		}
 	}
 }
 
 // storeBatchID stores a batch ID for later retrieval
-func storeBatchID(batchID, url string) {
+func storeBatchID(batchID, url string, runID int64) error {
 	// Store in database if available
-	if db != nil {
-		query := "INSERT INTO nmap_batches (batch_id, url, status, created_at) VALUES (?, ?, ?, ?)"
-		_, err := db.Exec(query, batchID, url, "running", time.Now())
-		if err != nil {
-			logger.Printf("Error storing batch ID: %v", err)
+	if store != nil {
+		if err := store.StoreBatchID(batchID, url, runID); err != nil {
+			return err
 		}
 	}
 	logger.Printf("Stored batch ID %s for URL %s", batchID, url)
+	return nil
+}
+
+// updateBatchStatus records the outcome of a batch and its raw results
+func updateBatchStatus(batchID, status string, results []byte) {
+	if store != nil {
+		if err := store.UpdateBatchStatus(batchID, status, string(results)); err != nil {
+			logger.Printf("Error updating status of batch %s: %v", batchID, err)
+		}
+	}
+}
+
+// storeNmapResults stores the hosts and open ports a batch found for a URL
+func storeNmapResults(batchID, url string, runID int64, results []NmapResult) {
+	if store != nil && len(results) > 0 {
+		if err := store.StoreNmapResults(batchID, url, runID, results); err != nil {
+			logger.Printf("Error storing port scan results of %s: %v", url, err)
+		}
+	}
 }
\ No newline at end of file
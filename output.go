@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported machine-readable output formats
+const (
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputCSV    = "csv"
+)
+
+// Outcome of processing a URL, as reported in output records
+const (
+	StatusScanned     = "scanned"
+	StatusExcluded    = "excluded"
+	StatusError       = "error"
+	StatusUnreachable = "unreachable"
+	StatusSkipped     = "skipped"
+)
+
+// urlRecord is the machine-readable form of a URLResult
+type urlRecord struct {
+	URL           string              `json:"url"`
+	RootURL       string              `json:"root_url,omitempty"`
+	ScannedURL    string              `json:"scanned_url,omitempty"`
+	Status        string              `json:"status"`
+	ExcludedBy    string              `json:"excluded_by,omitempty"`
+	Owner         string              `json:"owner,omitempty"`
+	Tags          []string            `json:"tags,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	RunID         int64               `json:"run_id,omitempty"`
+	ProcessTimeMS int64               `json:"process_time_ms"`
+	Reachability  *reachabilityRecord `json:"reachability,omitempty"`
+	Scripts       []scriptRecord      `json:"scripts"`
+	PortScan      []hostRecord        `json:"port_scan,omitempty"`
+}
+
+// reachabilityRecord is the machine-readable form of URLReachability
+type reachabilityRecord struct {
+	HTTPAvailable    bool      `json:"http_available"`
+	HTTPSAvailable   bool      `json:"https_available"`
+	HTTPStatusCode   int       `json:"http_status_code,omitempty"`
+	HTTPSStatusCode  int       `json:"https_status_code,omitempty"`
+	HTTPRedirectURL  string    `json:"http_redirect_url,omitempty"`
+	HTTPSRedirectURL string    `json:"https_redirect_url,omitempty"`
+	FinalURL         string    `json:"final_url,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
+
+// scriptRecord is the machine-readable form of a ScanResult
+type scriptRecord struct {
+	ScriptURL       string                `json:"script_url"`
+	PageURL         string                `json:"page_url,omitempty"`
+	Inline          bool                  `json:"inline,omitempty"`
+	Checksum        string                `json:"checksum"`
+	LibraryName     string                `json:"library_name"`
+	LibraryVersion  string                `json:"library_version,omitempty"`
+	IdentifiedBy    string                `json:"identified_by"`
+	Vulnerabilities []vulnerabilityRecord `json:"vulnerabilities,omitempty"`
+}
+
+// vulnerabilityRecord is the machine-readable form of a Vulnerability
+type vulnerabilityRecord struct {
+	Identifiers []string `json:"identifiers"`
+	Severity    string   `json:"severity,omitempty"`
+	FixedIn     string   `json:"fixed_in,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Info        []string `json:"info,omitempty"`
+}
+
+// hostRecord is the machine-readable form of an NmapResult
+type hostRecord struct {
+	IP        string       `json:"ip,omitempty"`
+	Hostname  string       `json:"hostname,omitempty"`
+	OpenPorts []portRecord `json:"open_ports"`
+}
+
+// portRecord is the machine-readable form of PortInfo
+type portRecord struct {
+	Port     string `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Service  string `json:"service,omitempty"`
+	Product  string `json:"product,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Status classifies the outcome of processing the URL
+func (r URLResult) Status() string {
+	switch {
+	case r.Excluded:
+		return StatusExcluded
+	case r.Error != nil || r.Reachability == nil:
+		return StatusError
+	case !r.Reachability.HTTPAvailable && !r.Reachability.HTTPSAvailable:
+		return StatusUnreachable
+	case r.Skipped:
+		return StatusSkipped
+	}
+	return StatusScanned
+}
+
+// newURLRecord converts a URLResult into its output record
+func newURLRecord(result URLResult) urlRecord {
+	record := urlRecord{
+		URL:           result.Job.URL,
+		RootURL:       result.Job.Root,
+		ScannedURL:    result.ScannedURL(),
+		Status:        result.Status(),
+		ExcludedBy:    result.ExcludedBy,
+		Owner:         result.Job.Owner,
+		Tags:          result.Job.Tags,
+		ProcessTimeMS: result.ProcessTime.Milliseconds(),
+		Scripts:       []scriptRecord{},
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+
+	if r := result.Reachability; r != nil {
+		record.RunID = r.RunID
+		record.Reachability = &reachabilityRecord{
+			HTTPAvailable:    r.HTTPAvailable,
+			HTTPSAvailable:   r.HTTPSAvailable,
+			HTTPStatusCode:   r.HTTPStatusCode,
+			HTTPSStatusCode:  r.HTTPSStatusCode,
+			HTTPRedirectURL:  r.HTTPRedirectURL,
+			HTTPSRedirectURL: r.HTTPSRedirectURL,
+			FinalURL:         r.FinalURL,
+			CheckedAt:        r.ScannedAt,
+		}
+	}
+
+	for _, s := range result.ScanResults {
+		// Within a URL record only scripts of crawled pages name their page
+		script := newScriptRecord(s)
+		script.PageURL = ""
+		if s.URL != record.ScannedURL {
+			script.PageURL = s.URL
+		}
+		record.Scripts = append(record.Scripts, script)
+	}
+	for _, host := range result.PortScan {
+		record.PortScan = append(record.PortScan, newHostRecord(host))
+	}
+
+	return record
+}
+
+// newScriptRecord converts a ScanResult into its output record
+func newScriptRecord(s ScanResult) scriptRecord {
+	script := scriptRecord{
+		ScriptURL:      s.ScriptURL,
+		Inline:         s.IsInline,
+		Checksum:       s.Checksum,
+		LibraryName:    s.LibraryName,
+		LibraryVersion: s.LibraryVersion,
+		IdentifiedBy:   s.IdentifiedBy,
+	}
+	// Scripts of crawled pages name the page they were found on
+	if s.RootURL != "" && s.URL != s.RootURL {
+		script.PageURL = s.URL
+	}
+	for _, v := range s.Vulnerabilities {
+		script.Vulnerabilities = append(script.Vulnerabilities, vulnerabilityRecord{
+			Identifiers: v.Identifiers,
+			Severity:    v.Severity,
+			FixedIn:     v.FixedIn,
+			Summary:     v.Summary,
+			Info:        v.Info,
+		})
+	}
+	return script
+}
+
+// newHostRecord converts an NmapResult into its output record
+func newHostRecord(host NmapResult) hostRecord {
+	h := hostRecord{IP: host.IP, Hostname: host.Hostname, OpenPorts: []portRecord{}}
+	for _, p := range host.OpenPorts {
+		h.OpenPorts = append(h.OpenPorts, portRecord{
+			Port:     p.Port,
+			Protocol: p.Protocol,
+			State:    p.State,
+			Service:  p.Service,
+			Product:  p.Product,
+			Version:  p.Version,
+		})
+	}
+	return h
+}
+
+// ResultWriter streams one record per processed URL as a JSON array or as
+// newline-delimited JSON, or one CSV row per script found. SARIF findings
+// are collected and written as a single log on Close.
+type ResultWriter struct {
+	format string
+	out    io.Writer
+	csv    *csv.Writer
+	sarif  []ScanResult
+	file   *os.File // Set when writing to a file rather than stdout
+	count  int
+	mu     sync.Mutex
+}
+
+// NewResultWriter creates a writer for the given format. An empty path or
+// "-" writes to stdout.
+func NewResultWriter(format, path string) (*ResultWriter, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case OutputJSON, OutputNDJSON, OutputCSV, OutputSARIF:
+	default:
+		return nil, fmt.Errorf("unsupported output format %q (use %s, %s, %s or %s)", format, OutputJSON, OutputNDJSON, OutputCSV, OutputSARIF)
+	}
+
+	w := &ResultWriter{format: format, out: os.Stdout}
+	if path != "" && path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not create output file %s: %v", path, err)
+		}
+		w.file = file
+		w.out = file
+	}
+
+	if format == OutputCSV {
+		w.csv = csv.NewWriter(w.out)
+		if err := w.csv.Write(inventoryCSVHeader); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// ToStdout reports whether records are written to standard output
+func (w *ResultWriter) ToStdout() bool {
+	return w.file == nil
+}
+
+// Write emits the record of a processed URL
+func (w *ResultWriter) Write(result URLResult) error {
+	switch w.format {
+	case OutputCSV:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return writeInventoryCSV(w.csv, result.ScanResults)
+	case OutputSARIF:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.sarif = append(w.sarif, result.ScanResults...)
+		return nil
+	}
+
+	data, err := json.Marshal(newURLRecord(result))
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.format == OutputJSON {
+		separator := ",\n  "
+		if w.count == 0 {
+			separator = "[\n  "
+		}
+		_, err = fmt.Fprintf(w.out, "%s%s", separator, data)
+	} else {
+		_, err = fmt.Fprintf(w.out, "%s\n", data)
+	}
+	w.count++
+	return err
+}
+
+// Close terminates the JSON array, flushes the CSV rows or writes the SARIF
+// log and closes the output file
+func (w *ResultWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	switch w.format {
+	case OutputCSV:
+		w.csv.Flush()
+		err = w.csv.Error()
+	case OutputSARIF:
+		err = writeSARIF(w.out, w.sarif)
+	case OutputJSON:
+		if w.count == 0 {
+			_, err = fmt.Fprint(w.out, "[]\n")
+		} else {
+			_, err = fmt.Fprint(w.out, "\n]\n")
+		}
+	}
+	if w.file != nil {
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
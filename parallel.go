@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,14 @@ type ParallelConfig struct {
 	BatchSize    int
 	UseDB        bool
 	Verbose      bool
+	SBOM         *SBOMWriter     // Optional per-site SBOM output
+	RunID        int64           // Scan run stored rows belong to, 0 if not recorded
+	Output       *ResultWriter   // Optional machine-readable record per URL
+	OnResult     func(URLResult) // Optional callback for each processed URL
+	Quiet        bool            // Suppress progress output, e.g. when serving the API
+	Crawl        CrawlConfig     // Same-origin pages to scan beyond each URL
+	Progress     io.Writer       // Progress and summary output, os.Stdout if nil
+	PortScan     PortScanConfig  // Optional port scan of the hosts of scanned URLs
 }
 
 // URLJob represents a URL to be processed
@@ -26,6 +35,17 @@ type URLJob struct {
 	URL           string
 	Index         int
 	OriginalIndex int
+	Owner         string   // Owning team from the input list, if given
+	Tags          []string // Tags from the input list
+	Root          string   // Input URL the page was discovered from, empty for input URLs
+}
+
+// annotate copies the input metadata of the job into its scan results
+func (job URLJob) annotate(results []ScanResult) {
+	for i := range results {
+		results[i].Owner = job.Owner
+		results[i].Tags = job.Tags
+	}
 }
 
 // URLResult represents the outcome of processing a URL
@@ -33,12 +53,29 @@ type URLResult struct {
 	Job          URLJob
 	Reachability *URLReachability
 	ScanResults  []ScanResult
+	PortScan     []NmapResult
 	Error        error
 	Excluded     bool
+	ExcludedBy   string // Name of the rule that excluded the URL
 	Skipped      bool
 	ProcessTime  time.Duration
 }
 
+// ScannedURL returns the final URL whose scripts were scanned, or an empty
+// string if the URL was excluded, unreachable or skipped
+func (r URLResult) ScannedURL() string {
+	if r.Excluded || r.Skipped || r.Error != nil || r.Reachability == nil {
+		return ""
+	}
+	if !r.Reachability.HTTPAvailable && !r.Reachability.HTTPSAvailable {
+		return ""
+	}
+	if r.Reachability.FinalURL != "" {
+		return r.Reachability.FinalURL
+	}
+	return r.Job.URL
+}
+
 // ProgressTracker provides thread-safe progress tracking
 type ProgressTracker struct {
 	total     int64
@@ -49,6 +86,8 @@ type ProgressTracker struct {
 	errors    int64
 	verbose   bool
 	mu        sync.RWMutex
+
+	excludedByRule map[string]int64 // Guarded by mu
 }
 
 // NewProgressTracker creates a new progress tracker
@@ -69,9 +108,27 @@ func (pt *ProgressTracker) IncrementScanned() {
 	atomic.AddInt64(&pt.scanned, 1)
 }
 
-// IncrementExcluded atomically increments excluded counter
-func (pt *ProgressTracker) IncrementExcluded() {
+// IncrementExcluded atomically increments excluded counter and counts the
+// exclusion against the rule that caused it
+func (pt *ProgressTracker) IncrementExcluded(rule string) {
 	atomic.AddInt64(&pt.excluded, 1)
+	pt.mu.Lock()
+	if pt.excludedByRule == nil {
+		pt.excludedByRule = make(map[string]int64)
+	}
+	pt.excludedByRule[rule]++
+	pt.mu.Unlock()
+}
+
+// ExcludedByRule returns the number of excluded URLs per rule
+func (pt *ProgressTracker) ExcludedByRule() map[string]int64 {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	counts := make(map[string]int64, len(pt.excludedByRule))
+	for rule, count := range pt.excludedByRule {
+		counts[rule] = count
+	}
+	return counts
 }
 
 // IncrementSkipped atomically increments skipped counter
@@ -97,6 +154,8 @@ func (pt *ProgressTracker) GetCounts() (processed, scanned, excluded, skipped, e
 type ParallelProcessor struct {
 	config  ParallelConfig
 	tracker *ProgressTracker
+	writer  *BatchWriter // Buffered database writes, nil without UseDB
+	crawl   *crawlRun    // Pages crawled in the current ProcessJobs call
 	mu      sync.Mutex // For synchronized output
 }
 
@@ -109,63 +168,151 @@ func NewParallelProcessor(config ParallelConfig) *ParallelProcessor {
 
 // ProcessURLs processes URLs in parallel using worker pool pattern
 func (pp *ParallelProcessor) ProcessURLs(ctx context.Context, urls []string) error {
-	pp.tracker = NewProgressTracker(len(urls), pp.config.Verbose)
+	jobs := make([]URLJob, len(urls))
+	for i, url := range urls {
+		jobs[i] = URLJob{URL: url}
+	}
+	return pp.ProcessJobs(ctx, jobs)
+}
+
+// ProcessJobs processes URLs together with their input metadata in parallel
+func (pp *ParallelProcessor) ProcessJobs(ctx context.Context, urlJobs []URLJob) error {
+	pp.mu.Lock()
+	pp.tracker = NewProgressTracker(len(urlJobs), pp.config.Verbose)
+	pp.crawl = newCrawlRun(urlJobs)
+	pp.writer = nil
+	if pp.config.UseDB {
+		pp.writer = NewBatchWriter(pp.config.BatchSize, batchFlushInterval)
+	}
+	pp.mu.Unlock()
 	
 	// Validate worker count
 	maxWorkers := pp.config.MaxWorkers
 	if maxWorkers <= 0 {
 		maxWorkers = 1
 	}
-	if maxWorkers > len(urls) {
-		maxWorkers = len(urls)
+	if maxWorkers > len(urlJobs) {
+		maxWorkers = len(urlJobs)
 	}
 	
 	// Create channels
-	jobs := make(chan URLJob, len(urls))
+	jobs := make(chan URLJob, len(urlJobs))
 	results := make(chan URLResult, maxWorkers*2) // Buffer for worker results
 	
 	// Start progress display (non-verbose mode)
-	if !pp.config.Verbose {
+	if !pp.config.Verbose && !pp.config.Quiet {
 		logger.Printf("Starting parallel processing with %d workers\n", maxWorkers)
 		pp.mu.Lock()
-		fmt.Printf("Processing %d URLs with %d workers...\n", len(urls), maxWorkers)
-		fmt.Print("Progress: ")
+		fmt.Fprintf(pp.progress(), "Processing %d URLs with %d workers...\n", len(urlJobs), maxWorkers)
+		fmt.Fprint(pp.progress(), "Progress: ")
 		pp.mu.Unlock()
 	}
 	
+	// Scanned URLs pass through the port scan stage on their way to the
+	// collector
+	scanned := results
+	portScanDone := make(chan struct{})
+	if pp.config.PortScan.Enabled {
+		scanned = make(chan URLResult, maxWorkers*2)
+		go pp.portScanStage(scanned, results, portScanDone)
+	} else {
+		close(portScanDone)
+	}
+	
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < maxWorkers; i++ {
 		wg.Add(1)
-		go pp.urlWorker(ctx, jobs, results, &wg)
+		go pp.urlWorker(ctx, jobs, scanned, &wg)
 	}
 	
 	// Start result collector
 	collectorDone := make(chan struct{})
-	go pp.resultCollector(results, len(urls), collectorDone)
+	go pp.resultCollector(results, len(urlJobs), collectorDone)
 	
-	// Send jobs
-	for i, url := range urls {
+	// Send jobs; on cancellation the workers finish the URLs they hold and
+	// everything scanned so far is still collected and stored below
+sendJobs:
+	for i, job := range urlJobs {
+		job.Index, job.OriginalIndex = i, i
 		select {
-		case jobs <- URLJob{URL: url, Index: i, OriginalIndex: i}:
+		case jobs <- job:
 		case <-ctx.Done():
-			close(jobs)
-			return ctx.Err()
+			break sendJobs
 		}
 	}
 	close(jobs)
 	
-	// Wait for workers to complete
+	// Wait for workers and pending port scans to complete
 	wg.Wait()
+	if pp.config.PortScan.Enabled {
+		close(scanned)
+	}
+	<-portScanDone
 	close(results)
 	
-	// Wait for result collector to finish
+	// Wait for result collector to finish and store the remaining rows
 	<-collectorDone
+	pp.closeWriter()
 	
 	// Final summary
 	pp.displayFinalSummary()
 	
-	return nil
+	return ctx.Err()
+}
+
+// progress returns the writer for progress and summary output
+func (pp *ParallelProcessor) progress() io.Writer {
+	if pp.config.Progress == nil {
+		return os.Stdout
+	}
+	return pp.config.Progress
+}
+
+// Counts returns the progress counters of the current or last ProcessJobs
+// call; it is safe to call while URLs are being processed
+func (pp *ParallelProcessor) Counts() (processed, scanned, excluded, skipped, errors int64) {
+	pp.mu.Lock()
+	tracker := pp.tracker
+	pp.mu.Unlock()
+	if tracker == nil {
+		return 0, 0, 0, 0, 0
+	}
+	return tracker.GetCounts()
+}
+
+// closeWriter writes the rows still buffered for the database
+func (pp *ParallelProcessor) closeWriter() {
+	if pp.writer == nil {
+		return
+	}
+	pp.writer.Close()
+	if dropped := pp.writer.Dropped(); dropped > 0 {
+		logger.Printf("%d database rows could not be stored\n", dropped)
+	}
+}
+
+// DroppedRows returns the number of database rows of the current or last
+// ProcessJobs call that could not be stored
+func (pp *ParallelProcessor) DroppedRows() int64 {
+	pp.mu.Lock()
+	writer := pp.writer
+	pp.mu.Unlock()
+	if writer == nil {
+		return 0
+	}
+	return writer.Dropped()
+}
+
+// ExcludedByRule returns the number of URLs excluded so far per rule
+func (pp *ParallelProcessor) ExcludedByRule() map[string]int64 {
+	pp.mu.Lock()
+	tracker := pp.tracker
+	pp.mu.Unlock()
+	if tracker == nil {
+		return nil
+	}
+	return tracker.ExcludedByRule()
 }
 
 // urlWorker processes URLs from the job queue
@@ -183,11 +330,9 @@ func (pp *ParallelProcessor) urlWorker(ctx context.Context, jobs <-chan URLJob,
 		result := pp.processURL(ctx, job)
 		result.ProcessTime = time.Since(startTime)
 		
-		select {
-		case results <- result:
-		case <-ctx.Done():
-			return
-		}
+		// Deliver the result even when cancelled, the collector drains
+		// the channel until all workers are done
+		results <- result
 		
 		// Rate limiting
 		if pp.config.RequestDelay > 0 {
@@ -204,10 +349,11 @@ func (pp *ParallelProcessor) processURL(ctx context.Context, job URLJob) URLResu
 	logger.Printf("Processing URL: %s\n", job.URL)
 	
 	// Check if URL should be excluded
-	if shouldExcludeURL(job.URL) {
-		pp.tracker.IncrementExcluded()
+	if rule := exclusionRule(job.URL); rule != "" {
+		pp.tracker.IncrementExcluded(rule)
 		result.Excluded = true
-		logger.Printf("Skipping excluded URL: %s\n", job.URL)
+		result.ExcludedBy = rule
+		logger.Printf("Skipping excluded URL: %s (rule: %s)\n", job.URL, rule)
 		return result
 	}
 	
@@ -220,13 +366,12 @@ func (pp *ParallelProcessor) processURL(ctx context.Context, job URLJob) URLResu
 		return result
 	}
 	
+	reachability.RunID = pp.config.RunID
 	result.Reachability = reachability
 	
 	// Store reachability data in database
-	if pp.config.UseDB && reachability != nil {
-		if err := storeURLReachability(reachability); err != nil {
-			logger.Printf("Error storing reachability data for %s: %v\n", job.URL, err)
-		}
+	if pp.writer != nil && reachability != nil {
+		pp.writer.AddReachability(reachability)
 	}
 	
 	// Check if URL is reachable
@@ -255,75 +400,156 @@ func (pp *ParallelProcessor) processURL(ctx context.Context, job URLJob) URLResu
 	logger.Printf("Scanning URL: %s\n", finalURL)
 	
 	// Perform JavaScript scanning
-	scanResults := pp.scanURLForResults(finalURL)
+	scanResults := pp.scanURLForResults(ctx, finalURL, job)
+	job.annotate(scanResults)
 	result.ScanResults = scanResults
 	
 	return result
 }
 
-// scanURLForResults performs JavaScript scanning and returns results
-func (pp *ParallelProcessor) scanURLForResults(baseURL string) []ScanResult {
+// scanURLForResults scans the page, and the pages crawled from it, for
+// scripts and returns the results attributed to the job's site
+func (pp *ParallelProcessor) scanURLForResults(ctx context.Context, baseURL string, job URLJob) []ScanResult {
 	var results []ScanResult
-	
-	logger.Printf("Fetching URL %s\n", baseURL)
-	resp, err := http.Get(baseURL)
-	if err != nil {
-		logger.Printf("Error fetching URL %s: %v\n", baseURL, err)
-		return results
-	}
-	defer resp.Body.Close()
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		logger.Printf("Error parsing HTML from %s: %v\n", baseURL, err)
-		return results
+	rootURL := job.Root
+	if rootURL == "" {
+		rootURL = baseURL
 	}
-
-	var scripts []string
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "script" {
-			for _, a := range n.Attr {
-				if a.Key == "src" {
-					scripts = append(scripts, a.Val)
-				}
+	crawl := pp.config.Crawl
+	crawl.Delay = pp.config.RequestDelay
+	pp.mu.Lock()
+	run := pp.crawl
+	pp.mu.Unlock()
+	err := crawlSite(ctx, baseURL, crawl, run, func(pageURL string, doc *html.Node) {
+		for _, outcome := range inspectPageScripts(pageURL, collectPageScripts(doc)) {
+			result := outcome.Result
+			if outcome.Err != nil || result == nil {
+				continue
 			}
+			result.RunID = pp.config.RunID
+			result.RootURL = rootURL
+			results = append(results, *result)
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
+	})
+	if err != nil {
+		logger.Printf("Error fetching URL %s: %v\n", baseURL, err)
 	}
-	f(doc)
+	
+	return results
+}
 
-	for _, scriptURL := range scripts {
-		fullScriptURL := toAbsoluteURL(baseURL, scriptURL)
-		logger.Printf("Processing script %s\n", fullScriptURL)
-		checksum, jsCode, err := getScriptChecksumAndContent(fullScriptURL)
-		if err != nil {
-			logger.Printf("Error processing script %s: %v\n", fullScriptURL, err)
-			continue
+// portScanStage port scans the hosts of scanned URLs and forwards every
+// result to out. Hosts are grouped into nmap batches of up to
+// PortScan.BatchSize hosts, a batch is started once it is full or has waited
+// portScanBatchWait, and up to PortScan.Workers batches run at once.
+func (pp *ParallelProcessor) portScanStage(in <-chan URLResult, out chan<- URLResult, done chan<- struct{}) {
+	defer close(done)
+	
+	config := pp.config.PortScan
+	workers := config.Workers
+	if workers <= 0 {
+		workers = defaultPortScanWorkers
+	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPortScanBatchSize
+	}
+	
+	// The nmap service is started once, by the first batch
+	var serviceOnce sync.Once
+	var serviceErr error
+	
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, workers)
+	var pending []URLResult
+	var hosts []string
+	seen := make(map[string]bool)
+	timer := time.NewTimer(portScanBatchWait)
+	timer.Stop()
+	
+	flush := func() {
+		timer.Stop()
+		if len(pending) == 0 {
+			return
 		}
+		batch, batchHosts := pending, hosts
+		pending, hosts, seen = nil, nil, make(map[string]bool)
 		
-		logger.Printf("Found script: %s, Checksum: %s\n", fullScriptURL, checksum)
-
-		libraryInfo := identifyLibrary(fullScriptURL, checksum, jsCode)
-		if libraryInfo != nil {
-			logger.Printf("Identified library for %s as: %s v%s (%s) [checksum: %s]\n", 
-				fullScriptURL, libraryInfo.Name, libraryInfo.Version, libraryInfo.Method, libraryInfo.Checksum)
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
 			
-			result := ScanResult{
-				URL:              baseURL,
-				ScriptURL:        fullScriptURL,
-				Checksum:         checksum,
-				LibraryName:      libraryInfo.Name,
-				LibraryVersion:   libraryInfo.Version,
-				IdentifiedBy:     libraryInfo.Method,
+			serviceOnce.Do(func() {
+				if serviceErr = ensureNmapService(pp.progress()); serviceErr != nil {
+					logger.Printf("Port scanning disabled: %v\n", serviceErr)
+				}
+			})
+			if serviceErr == nil {
+				pp.scanPortBatch(batch, batchHosts)
 			}
-			results = append(results, result)
+			for _, result := range batch {
+				out <- result
+			}
+		}()
+	}
+	
+	for {
+		select {
+		case result, ok := <-in:
+			if !ok {
+				flush()
+				wg.Wait()
+				return
+			}
+			
+			host := ""
+			if scannedURL := result.ScannedURL(); scannedURL != "" {
+				host = portScanHost(scannedURL)
+			}
+			if host == "" {
+				out <- result
+				continue
+			}
+			
+			if !seen[host] {
+				if len(hosts) >= batchSize {
+					flush()
+				}
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+			pending = append(pending, result)
+			if len(pending) == 1 {
+				timer.Reset(portScanBatchWait)
+			}
+		case <-timer.C:
+			flush()
 		}
 	}
+}
+
+// scanPortBatch scans the hosts in one nmap batch and attaches the results
+// of each URL's host to it
+func (pp *ParallelProcessor) scanPortBatch(batch []URLResult, hosts []string) {
+	config := pp.config.PortScan
+	logger.Printf("Port scanning %d hosts of %d URLs\n", len(hosts), len(batch))
 	
-	return results
+	// The hosts are stored per URL in nmap_hosts, the batch gets a label that
+	// fits the url column
+	label := fmt.Sprintf("batch:%d hosts", len(hosts))
+	batchID, nmapResults, err := runNmapBatch(hosts, config.Ports, config.Options, label, pp.config.RunID)
+	if err != nil {
+		logger.Printf("Port scan of %s failed: %v\n", strings.Join(hosts, ", "), err)
+		return
+	}
+	for i := range batch {
+		scannedURL := batch[i].ScannedURL()
+		batch[i].PortScan = hostResults(nmapResults, portScanHost(scannedURL))
+		storeNmapResults(batchID, scannedURL, pp.config.RunID, batch[i].PortScan)
+	}
 }
 
 // resultCollector processes results as they come in
@@ -336,16 +562,34 @@ func (pp *ParallelProcessor) resultCollector(results <-chan URLResult, expectedC
 		processedCount++
 		
 		// Store scan results in database
-		if pp.config.UseDB && len(result.ScanResults) > 0 {
-			for _, scanResult := range result.ScanResults {
-				if err := storeResult(scanResult); err != nil {
-					logger.Printf("Error storing result for %s: %v\n", scanResult.ScriptURL, err)
+		if pp.writer != nil {
+			pp.writer.AddResults(result.ScanResults...)
+		}
+		
+		// Write the site's SBOM
+		if pp.config.SBOM != nil {
+			if scannedURL := result.ScannedURL(); scannedURL != "" {
+				if _, err := pp.config.SBOM.Write(scannedURL, result.ScanResults); err != nil {
+					logger.Printf("Error writing SBOM for %s: %v\n", scannedURL, err)
 				}
 			}
 		}
 		
+		// Emit the machine-readable record
+		if pp.config.Output != nil {
+			if err := pp.config.Output.Write(result); err != nil {
+				logger.Printf("Error writing output record for %s: %v\n", result.Job.URL, err)
+			}
+		}
+		
+		if pp.config.OnResult != nil {
+			pp.config.OnResult(result)
+		}
+		
 		// Update progress display
-		pp.updateProgressDisplay(result)
+		if !pp.config.Quiet {
+			pp.updateProgressDisplay(result)
+		}
 		
 		// Check if we're done
 		if processedCount >= expectedCount {
@@ -363,12 +607,12 @@ func (pp *ParallelProcessor) updateProgressDisplay(result URLResult) {
 	
 	if pp.config.Verbose {
 		// Verbose output for each result
-		fmt.Printf("\nProcessing URL: %s\n", result.Job.URL)
+		fmt.Fprintf(pp.progress(), "\nProcessing URL: %s\n", result.Job.URL)
 		
 		if result.Excluded {
-			fmt.Printf("  - Skipping excluded URL (Microsoft login domain)\n")
+			fmt.Fprintf(pp.progress(), "  - Skipping excluded URL (rule: %s)\n", result.ExcludedBy)
 		} else if result.Error != nil {
-			fmt.Printf("  - Error checking reachability: %v\n", result.Error)
+			fmt.Fprintf(pp.progress(), "  - Error checking reachability: %v\n", result.Error)
 		} else if result.Reachability != nil {
 			if result.Reachability.HTTPAvailable || result.Reachability.HTTPSAvailable {
 				protocols := []string{}
@@ -378,33 +622,44 @@ func (pp *ParallelProcessor) updateProgressDisplay(result URLResult) {
 				if result.Reachability.HTTPSAvailable {
 					protocols = append(protocols, fmt.Sprintf("HTTPS (%d)", result.Reachability.HTTPSStatusCode))
 				}
-				fmt.Printf("  - Reachable via: %s\n", strings.Join(protocols, ", "))
+				fmt.Fprintf(pp.progress(), "  - Reachable via: %s\n", strings.Join(protocols, ", "))
 				
 				if result.Reachability.HTTPRedirectURL != "" || result.Reachability.HTTPSRedirectURL != "" {
-					fmt.Printf("  - Redirects detected\n")
+					fmt.Fprintf(pp.progress(), "  - Redirects detected\n")
 				}
 				
 				if result.Reachability.FinalURL != "" && result.Reachability.FinalURL != result.Job.URL {
-					fmt.Printf("  - Final URL: %s\n", result.Reachability.FinalURL)
+					fmt.Fprintf(pp.progress(), "  - Final URL: %s\n", result.Reachability.FinalURL)
 				}
 				
 				if result.Skipped {
-					fmt.Printf("  - Skipping JavaScript scan (no HTTP 200 response)\n")
+					fmt.Fprintf(pp.progress(), "  - Skipping JavaScript scan (no HTTP 200 response)\n")
 				} else if len(result.ScanResults) > 0 {
-					fmt.Printf("  - Scanning for JavaScript libraries...\n")
+					fmt.Fprintf(pp.progress(), "  - Scanning for JavaScript libraries...\n")
 					for _, scanResult := range result.ScanResults {
+						inlineNote := ""
+						if scanResult.IsInline {
+							inlineNote = fmt.Sprintf(" (%s)", scanResult.ScriptURL)
+						}
 						if scanResult.LibraryVersion != "unknown" && scanResult.LibraryVersion != "" {
-							fmt.Printf("    Library: %s v%s (%s) [%s...]\n", 
+							fmt.Fprintf(pp.progress(), "    Library: %s v%s (%s) [%s...]%s\n", 
 								scanResult.LibraryName, scanResult.LibraryVersion, 
-								scanResult.IdentifiedBy, scanResult.Checksum[:8])
+								scanResult.IdentifiedBy, scanResult.Checksum[:8], inlineNote)
 						} else {
-							fmt.Printf("    Library: %s (%s) [%s...]\n", 
-								scanResult.LibraryName, scanResult.IdentifiedBy, scanResult.Checksum[:8])
+							fmt.Fprintf(pp.progress(), "    Library: %s (%s) [%s...]%s\n", 
+								scanResult.LibraryName, scanResult.IdentifiedBy, scanResult.Checksum[:8], inlineNote)
+						}
+						if len(scanResult.Vulnerabilities) > 0 {
+							fmt.Fprintf(pp.progress(), "    Vulnerable: %s\n", formatVulnerabilities(scanResult.Vulnerabilities))
 						}
 					}
 				}
+				
+				if len(result.PortScan) > 0 {
+					displayNmapResults(pp.progress(), result.PortScan, result.ScannedURL())
+				}
 			} else {
-				fmt.Printf("  - URL not reachable\n")
+				fmt.Fprintf(pp.progress(), "  - URL not reachable\n")
 			}
 		}
 	} else {
@@ -415,37 +670,38 @@ func (pp *ParallelProcessor) updateProgressDisplay(result URLResult) {
 				finalURL = result.Reachability.FinalURL
 			}
 			
-			fmt.Printf("\n[%d/%d] Scanning: %s → %d scripts found", 
+			fmt.Fprintf(pp.progress(), "\n[%d/%d] Scanning: %s → %d scripts found", 
 				processed, pp.tracker.total, finalURL, len(result.ScanResults))
-			fmt.Print("\nProgress: ")
+			fmt.Fprint(pp.progress(), "\nProgress: ")
 		}
 		
 		// Progress dots
 		if processed%10 == 0 || processed == pp.tracker.total {
-			fmt.Printf(" %d", processed)
+			fmt.Fprintf(pp.progress(), " %d", processed)
 		} else {
-			fmt.Print(".")
+			fmt.Fprint(pp.progress(), ".")
 		}
 	}
 }
 
 // displayFinalSummary displays the final summary
 func (pp *ParallelProcessor) displayFinalSummary() {
-	if !pp.config.Verbose {
+	if !pp.config.Verbose && !pp.config.Quiet {
 		processed, scanned, excluded, skipped, errors := pp.tracker.GetCounts()
 		
 		pp.mu.Lock()
-		fmt.Printf("\n\nScan completed!\n")
-		fmt.Printf("Total URLs processed: %d\n", processed)
-		fmt.Printf("Successfully scanned: %d\n", scanned)
-		if excluded > 0 {
-			fmt.Printf("Excluded URLs: %d\n", excluded)
-		}
+		fmt.Fprintf(pp.progress(), "\n\nScan completed!\n")
+		fmt.Fprintf(pp.progress(), "Total URLs processed: %d\n", processed)
+		fmt.Fprintf(pp.progress(), "Successfully scanned: %d\n", scanned)
+		printExclusionSummary(pp.progress(), excluded, pp.tracker.ExcludedByRule())
 		if skipped > 0 {
-			fmt.Printf("Skipped (non-200): %d\n", skipped)
+			fmt.Fprintf(pp.progress(), "Skipped (non-200): %d\n", skipped)
 		}
 		if errors > 0 {
-			fmt.Printf("Errors/Unreachable: %d\n", errors)
+			fmt.Fprintf(pp.progress(), "Errors/Unreachable: %d\n", errors)
+		}
+		if pp.writer != nil && pp.writer.Dropped() > 0 {
+			fmt.Fprintf(pp.progress(), "Database rows dropped: %d\n", pp.writer.Dropped())
 		}
 		pp.mu.Unlock()
 	}
@@ -18,6 +18,7 @@ type URLReachability struct {
 	HTTPRedirectURL string
 	HTTPSRedirectURL string
 	FinalURL        string
+	RunID           int64 // Scan run that checked the URL, 0 if not recorded
 	ScannedAt       time.Time
 }
 
@@ -47,10 +48,10 @@ func checkURLReachability(inputURL string) (*URLReachability, error) {
 	
 	// Create HTTP client with timeout and redirect handling
 	client := &http.Client{
-		Timeout: 15 * time.Second,
+		Timeout: reachabilityTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Allow up to 10 redirects
-			if len(via) >= 10 {
+			// Allow up to maxRedirects redirects
+			if len(via) >= maxRedirects {
 				return fmt.Errorf("too many redirects")
 			}
 			return nil
@@ -143,9 +144,9 @@ func determineRedirectURL(originalURL, redirectURL string) string {
 // checkAndFollowRedirects checks a URL and follows redirects to get the final URL
 func checkAndFollowRedirects(inputURL string) (string, error) {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: redirectTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
+			if len(via) >= maxRedirects {
 				return fmt.Errorf("too many redirects")
 			}
 			return nil
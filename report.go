@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportTemplates holds the HTML report template; CSS is inlined so the
+// generated report works offline as a single file
+//
+//go:embed templates/report.html
+var reportTemplates embed.FS
+
+// reportData is everything rendered into an HTML report
+type reportData struct {
+	Title        string
+	Source       string
+	GeneratedAt  time.Time
+	Sites        []reportSite
+	Libraries    []reportLibrary
+	Reachability *URLReachabilityStats
+	Hosts        []reportHost
+	TotalScripts int
+	Vulnerable   int // Scripts with known advisories
+}
+
+// reportSite lists the scripts found on one site
+type reportSite struct {
+	URL     string
+	Owner   string
+	Tags    []string
+	Scripts []scriptRecord
+}
+
+// reportLibrary is one bar of the library distribution chart
+type reportLibrary struct {
+	Name    string
+	Sites   int
+	Percent float64 // Share of scanned sites using the library
+}
+
+// reportHost lists the open ports found for a scanned URL
+type reportHost struct {
+	URL      string
+	IP       string
+	Hostname string
+	Ports    []portRecord
+}
+
+// reportChartLimit caps the number of libraries in the distribution chart
+const reportChartLimit = 20
+
+// buildReportFromStore assembles report data from the database
+func buildReportFromStore(runID int64) (*reportData, error) {
+	data := &reportData{Title: "NetWeather Report", Source: "database (all scan runs)"}
+	if runID != 0 {
+		data.Source = fmt.Sprintf("database, scan run %d", runID)
+	}
+
+	results, err := getScanResults(runID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving scan results: %v", err)
+	}
+	bySite := make(map[string]*reportSite)
+	for _, r := range results {
+		site, exists := bySite[r.URL]
+		if !exists {
+			site = &reportSite{URL: r.URL}
+			bySite[r.URL] = site
+		}
+		// Results are ordered by ID, so the latest metadata wins
+		if r.Owner != "" || len(r.Tags) > 0 {
+			site.Owner, site.Tags = r.Owner, r.Tags
+		}
+		site.Scripts = append(site.Scripts, newScriptRecord(r))
+	}
+	for _, key := range sortedKeys(bySite) {
+		data.Sites = append(data.Sites, *bySite[key])
+	}
+
+	if data.Reachability, err = getURLReachabilityStatistics(runID); err != nil {
+		return nil, fmt.Errorf("error retrieving reachability statistics: %v", err)
+	}
+
+	batches, err := store.NmapBatches(runID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving port scan batches: %v", err)
+	}
+	for _, batch := range batches {
+		if batch.Results == "" {
+			continue
+		}
+		nmapResults, err := parseNmapXML([]byte(batch.Results))
+		if err != nil {
+			logger.Printf("Error parsing results of batch %s: %v\n", batch.BatchID, err)
+			continue
+		}
+		data.Hosts = append(data.Hosts, reportHosts(batch.URL, nmapResults)...)
+	}
+
+	data.summarize()
+	return data, nil
+}
+
+// buildReportFromFile assembles report data from a -output json or ndjson file
+func buildReportFromFile(path string) (*reportData, error) {
+	records, err := readURLRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &reportData{Title: "NetWeather Report", Source: path, Reachability: &URLReachabilityStats{}}
+	for _, record := range records {
+		if r := record.Reachability; r != nil {
+			reach := data.Reachability
+			reach.TotalChecked++
+			switch {
+			case r.HTTPAvailable && r.HTTPSAvailable:
+				reach.BothProtocolsCount++
+			case r.HTTPAvailable:
+				reach.HTTPOnlyCount++
+			case r.HTTPSAvailable:
+				reach.HTTPSOnlyCount++
+			default:
+				reach.UnreachableCount++
+			}
+			if r.HTTPRedirectURL != "" || r.HTTPSRedirectURL != "" {
+				reach.RedirectCount++
+			}
+		}
+
+		if record.Status == StatusScanned {
+			data.Sites = append(data.Sites, reportSite{URL: record.ScannedURL, Owner: record.Owner, Tags: record.Tags, Scripts: record.Scripts})
+		}
+		for _, host := range record.PortScan {
+			data.Hosts = append(data.Hosts, reportHost{URL: record.URL, IP: host.IP, Hostname: host.Hostname, Ports: host.OpenPorts})
+		}
+	}
+	sort.Slice(data.Sites, func(i, j int) bool { return data.Sites[i].URL < data.Sites[j].URL })
+
+	data.summarize()
+	return data, nil
+}
+
+// readURLRecords reads records written with -output json (an array) or
+// -output ndjson (one object per line)
+func readURLRecords(path string) ([]urlRecord, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []urlRecord
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", path, err)
+		}
+		return records, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record urlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("error parsing %s line %d: %v", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// reportHosts converts parsed nmap results of a URL into report rows
+func reportHosts(url string, results []NmapResult) []reportHost {
+	hosts := make([]reportHost, 0, len(results))
+	for _, result := range results {
+		host := newHostRecord(result)
+		hosts = append(hosts, reportHost{URL: url, IP: host.IP, Hostname: host.Hostname, Ports: host.OpenPorts})
+	}
+	return hosts
+}
+
+// summarize computes the totals and the library distribution
+func (d *reportData) summarize() {
+	d.GeneratedAt = time.Now()
+
+	sitesPerLibrary := make(map[string]int)
+	for _, site := range d.Sites {
+		seen := make(map[string]bool)
+		for _, script := range site.Scripts {
+			d.TotalScripts++
+			if len(script.Vulnerabilities) > 0 {
+				d.Vulnerable++
+			}
+			name := script.LibraryName
+			if name == "" || strings.EqualFold(name, "unknown") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			sitesPerLibrary[name]++
+		}
+	}
+
+	for name, count := range sitesPerLibrary {
+		d.Libraries = append(d.Libraries, reportLibrary{
+			Name:    name,
+			Sites:   count,
+			Percent: 100 * float64(count) / float64(len(d.Sites)),
+		})
+	}
+	sort.Slice(d.Libraries, func(i, j int) bool {
+		if d.Libraries[i].Sites != d.Libraries[j].Sites {
+			return d.Libraries[i].Sites > d.Libraries[j].Sites
+		}
+		return d.Libraries[i].Name < d.Libraries[j].Name
+	})
+	if len(d.Libraries) > reportChartLimit {
+		d.Libraries = d.Libraries[:reportChartLimit]
+	}
+}
+
+// reportFuncs are the helpers available to the report template
+var reportFuncs = template.FuncMap{
+	"percent": func(part, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(part) / float64(total)
+	},
+	"join": strings.Join,
+	"vulnerabilities": func(vulns []vulnerabilityRecord) string {
+		var parts []string
+		for _, v := range vulns {
+			line := strings.Join(v.Identifiers, ", ")
+			if v.Severity != "" {
+				line += " (" + v.Severity + ")"
+			}
+			if v.FixedIn != "" {
+				line += ", fixed in " + v.FixedIn
+			}
+			parts = append(parts, line)
+		}
+		return strings.Join(parts, "; ")
+	},
+}
+
+// writeHTMLReport renders the report into a single self-contained HTML file
+func writeHTMLReport(data *reportData, path string) error {
+	tmpl, err := template.New("report.html").Funcs(reportFuncs).ParseFS(reportTemplates, "templates/report.html")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// runReportCommand implements "netweather report -format html [results.json]"
+// and returns the process exit code. Without a results file the report is
+// generated from the database.
+func runReportCommand(args []string, format string, runID int64, outputFile string) int {
+	if format != "html" {
+		fmt.Printf("Unsupported report format %q (use -format html)\n", format)
+		return 1
+	}
+	if len(args) > 1 {
+		fmt.Println("Usage: netweather report -format html [db-options] [-run id] [-output-file file] [results.json]")
+		return 1
+	}
+	if outputFile == "" {
+		outputFile = "netweather-report.html"
+	}
+
+	var data *reportData
+	var err error
+	if len(args) == 1 {
+		data, err = buildReportFromFile(args[0])
+	} else {
+		data, err = buildReportFromStore(runID)
+	}
+	if err != nil {
+		fmt.Printf("Error building report: %v\n", err)
+		return 1
+	}
+
+	if err := writeHTMLReport(data, outputFile); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Report with %d sites written to %s\n", len(data.Sites), outputFile)
+	return 0
+}
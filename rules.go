@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule types, each matching one part of a URL
+const (
+	RuleHost   = "host"   // Exact hostname
+	RuleSuffix = "suffix" // Hostname or any of its subdomains
+	RuleGlob   = "glob"   // Hostname glob such as *.example.com
+	RuleRegex  = "regex"  // Regular expression on the full URL
+	RuleCIDR   = "cidr"   // IP address targets within a network
+	RulePath   = "path"   // URL path prefix
+)
+
+// Rule actions
+const (
+	RuleExclude = "exclude"
+	RuleInclude = "include"
+)
+
+// notIncludedRule is reported for URLs that match none of the include rules
+const notIncludedRule = "not-included"
+
+// URLRule decides whether URLs are scanned
+type URLRule struct {
+	Name    string `yaml:"name"`
+	Action  string `yaml:"action"` // exclude (default) or include
+	Type    string `yaml:"type"`
+	Pattern string `yaml:"pattern"`
+
+	regex   *regexp.Regexp
+	network *net.IPNet
+}
+
+// URLRules are the exclusion and inclusion rules applied to every input URL.
+// Exclusions take precedence; if there are include rules, URLs that match
+// none of them are excluded as well.
+type URLRules struct {
+	Exclude []*URLRule
+	Include []*URLRule
+}
+
+// urlRules holds the rules in effect, set up by configureURLRules
+var urlRules = &URLRules{}
+
+// rulesFile is the YAML file given with -rules
+type rulesFile struct {
+	Rules []URLRule `yaml:"rules"`
+}
+
+// loadURLRules reads and compiles a rules file
+func loadURLRules(filePath string) ([]*URLRule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var content rulesFile
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&content); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", filePath, err)
+	}
+
+	rules := make([]*URLRule, 0, len(content.Rules))
+	names := make(map[string]bool)
+	for i := range content.Rules {
+		rule := &content.Rules[i]
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d in %s: %v", i+1, filePath, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q in %s", rule.Name, filePath)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compile validates the rule and prepares its matcher
+func (r *URLRule) compile() error {
+	r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+	r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+	if r.Action == "" {
+		r.Action = RuleExclude
+	}
+	if r.Action != RuleExclude && r.Action != RuleInclude {
+		return fmt.Errorf("unknown action %q (use %s or %s)", r.Action, RuleExclude, RuleInclude)
+	}
+	if r.Pattern == "" {
+		return errors.New("pattern is empty")
+	}
+	if r.Name == "" {
+		r.Name = r.Type + ":" + r.Pattern
+	}
+
+	switch r.Type {
+	case RuleHost, RuleSuffix:
+		r.Pattern = strings.ToLower(strings.TrimPrefix(r.Pattern, "."))
+	case RuleGlob:
+		r.Pattern = strings.ToLower(r.Pattern)
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %v", r.Pattern, err)
+		}
+	case RuleRegex:
+		regex, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex %q: %v", r.Pattern, err)
+		}
+		r.regex = regex
+	case RuleCIDR:
+		_, network, err := net.ParseCIDR(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q: %v", r.Pattern, err)
+		}
+		r.network = network
+	case RulePath:
+		if !strings.HasPrefix(r.Pattern, "/") {
+			return fmt.Errorf("path prefix %q must start with /", r.Pattern)
+		}
+	default:
+		return fmt.Errorf("unknown type %q (use host, suffix, glob, regex, cidr or path)", r.Type)
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to the URL. parsed is nil if the
+// URL could not be parsed, in which case host rules fall back to a substring
+// match on the raw URL.
+func (r *URLRule) Matches(rawURL string, parsed *url.URL) bool {
+	if parsed == nil {
+		switch r.Type {
+		case RuleHost, RuleSuffix:
+			return strings.Contains(strings.ToLower(rawURL), r.Pattern)
+		case RuleRegex:
+			return r.regex.MatchString(rawURL)
+		}
+		return false
+	}
+
+	hostname := strings.ToLower(parsed.Hostname())
+	switch r.Type {
+	case RuleHost:
+		return hostname == r.Pattern
+	case RuleSuffix:
+		return hostname == r.Pattern || strings.HasSuffix(hostname, "."+r.Pattern)
+	case RuleGlob:
+		matched, _ := path.Match(r.Pattern, hostname)
+		return matched
+	case RuleRegex:
+		return r.regex.MatchString(rawURL)
+	case RuleCIDR:
+		ip := net.ParseIP(hostname)
+		return ip != nil && r.network.Contains(ip)
+	case RulePath:
+		urlPath := parsed.Path
+		if urlPath == "" {
+			urlPath = "/"
+		}
+		return strings.HasPrefix(urlPath, r.Pattern)
+	}
+	return false
+}
+
+// Match returns the name of the rule that excludes the URL, or an empty
+// string if the URL is to be scanned
+func (rs *URLRules) Match(inputURL string) string {
+	if rs == nil {
+		return ""
+	}
+
+	// Input URLs may omit the protocol; parse them as http so the
+	// hostname is recognised
+	target := inputURL
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		parsed = nil
+	}
+
+	for _, rule := range rs.Exclude {
+		if rule.Matches(inputURL, parsed) {
+			return rule.Name
+		}
+	}
+	if len(rs.Include) == 0 {
+		return ""
+	}
+	for _, rule := range rs.Include {
+		if rule.Matches(inputURL, parsed) {
+			return ""
+		}
+	}
+	return notIncludedRule
+}
+
+// configureURLRules sets up urlRules from the excluded domains of the
+// configuration and the rules file, if any
+func configureURLRules(rulesPath string) error {
+	rules := &URLRules{}
+	for _, domain := range excludedDomains {
+		rule := &URLRule{Name: domain, Type: RuleSuffix, Pattern: domain}
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("excluded domain %q: %v", domain, err)
+		}
+		rules.Exclude = append(rules.Exclude, rule)
+	}
+
+	if rulesPath != "" {
+		fileRules, err := loadURLRules(rulesPath)
+		if err != nil {
+			return err
+		}
+		for _, rule := range fileRules {
+			if rule.Action == RuleInclude {
+				rules.Include = append(rules.Include, rule)
+			} else {
+				rules.Exclude = append(rules.Exclude, rule)
+			}
+		}
+		logger.Printf("Loaded %d URL rules from %s\n", len(fileRules), rulesPath)
+	}
+
+	urlRules = rules
+	return nil
+}
+
+// exclusionRule returns the name of the rule that excludes the URL from
+// scanning, or an empty string if it is to be scanned
+func exclusionRule(inputURL string) string {
+	return urlRules.Match(inputURL)
+}
+
+// printExclusionSummary prints the excluded URL count broken down by rule
+func printExclusionSummary(w io.Writer, excluded int64, byRule map[string]int64) {
+	if excluded == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Excluded URLs: %d\n", excluded)
+
+	names := make([]string, 0, len(byRule))
+	for name := range byRule {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  - %s: %d\n", name, byRule[name])
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func compiledRule(t *testing.T, action, ruleType, pattern string) *URLRule {
+	t.Helper()
+	rule := &URLRule{Action: action, Type: ruleType, Pattern: pattern}
+	if err := rule.compile(); err != nil {
+		t.Fatalf("compile %s %q: %v", ruleType, pattern, err)
+	}
+	return rule
+}
+
+func TestURLRuleMatches(t *testing.T) {
+	tests := []struct {
+		ruleType string
+		pattern  string
+		url      string
+		want     bool
+	}{
+		{RuleHost, "example.com", "https://example.com/login", true},
+		{RuleHost, "Example.COM", "https://EXAMPLE.com:8443/", true},
+		{RuleHost, "example.com", "https://www.example.com/", false},
+		{RuleSuffix, ".example.com", "https://example.com/", true},
+		{RuleSuffix, "example.com", "https://login.eu.example.com/", true},
+		{RuleSuffix, "example.com", "https://notexample.com/", false},
+		{RuleGlob, "*.staging.example.com", "https://app.staging.example.com/", true},
+		{RuleGlob, "*.staging.example.com", "https://staging.example.com/", false},
+		{RuleGlob, "shop-?.example.com", "http://shop-1.example.com/", true},
+		{RuleRegex, `\.(pdf|zip)$`, "https://example.com/files/report.pdf", true},
+		{RuleRegex, `^https://`, "http://example.com/", false},
+		{RuleCIDR, "10.0.0.0/8", "http://10.1.2.3/", true},
+		{RuleCIDR, "10.0.0.0/8", "http://192.168.1.1/", false},
+		{RuleCIDR, "10.0.0.0/8", "http://ten.example.com/", false},
+		{RuleCIDR, "2001:db8::/32", "http://[2001:db8::1]:8080/", true},
+		{RulePath, "/admin", "https://example.com/admin/users", true},
+		{RulePath, "/admin", "https://example.com/", false},
+		{RulePath, "/", "https://example.com", true},
+	}
+	for _, tt := range tests {
+		rule := compiledRule(t, "", tt.ruleType, tt.pattern)
+		rules := &URLRules{Exclude: []*URLRule{rule}}
+		if got := rules.Match(tt.url) != ""; got != tt.want {
+			t.Errorf("%s %q matches %q = %v, want %v", tt.ruleType, tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestURLRulesMatch(t *testing.T) {
+	rules := &URLRules{
+		Exclude: []*URLRule{
+			compiledRule(t, RuleExclude, RuleSuffix, "login.microsoftonline.com"),
+			compiledRule(t, RuleExclude, RulePath, "/internal"),
+		},
+		Include: []*URLRule{
+			compiledRule(t, RuleInclude, RuleSuffix, "example.com"),
+			compiledRule(t, RuleInclude, RuleSuffix, "login.microsoftonline.com"),
+		},
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://shop.example.com/", ""},
+		{"shop.example.com", ""},
+		{"https://login.microsoftonline.com/", "suffix:login.microsoftonline.com"},
+		{"https://example.com/internal/tools", "path:/internal"},
+		{"https://example.org/", notIncludedRule},
+	}
+	for _, tt := range tests {
+		if got := rules.Match(tt.url); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	var none *URLRules
+	if got := none.Match("https://example.com/"); got != "" {
+		t.Errorf("Match without rules = %q, want none", got)
+	}
+}
+
+func TestURLRuleCompileErrors(t *testing.T) {
+	tests := []struct {
+		rule URLRule
+		want string
+	}{
+		{URLRule{Type: RuleHost}, "pattern is empty"},
+		{URLRule{Type: "domain", Pattern: "example.com"}, "unknown type"},
+		{URLRule{Action: "skip", Type: RuleHost, Pattern: "example.com"}, "unknown action"},
+		{URLRule{Type: RuleGlob, Pattern: "[a-"}, "invalid glob"},
+		{URLRule{Type: RuleRegex, Pattern: "("}, "invalid regex"},
+		{URLRule{Type: RuleCIDR, Pattern: "10.0.0.0"}, "invalid CIDR"},
+		{URLRule{Type: RulePath, Pattern: "admin"}, "must start with /"},
+	}
+	for _, tt := range tests {
+		rule := tt.rule
+		if err := rule.compile(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compile(%+v) = %v, want an error containing %q", tt.rule, err, tt.want)
+		}
+	}
+}
+
+func TestLoadURLRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	content := `rules:
+  - name: no-staging
+    type: glob
+    pattern: "*.staging.example.com"
+  - action: include
+    type: suffix
+    pattern: example.com
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadURLRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Name != "no-staging" || rules[0].Action != RuleExclude ||
+		rules[1].Name != "suffix:example.com" || rules[1].Action != RuleInclude {
+		t.Errorf("loaded rules %+v, %+v", rules[0], rules[1])
+	}
+
+	duplicate := content + "  - name: no-staging\n    type: host\n    pattern: staging.example.com\n"
+	if err := os.WriteFile(path, []byte(duplicate), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadURLRules(path); err == nil || !strings.Contains(err.Error(), "duplicate rule name") {
+		t.Errorf("duplicate names: err = %v", err)
+	}
+}
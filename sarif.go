@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutputSARIF writes all findings of a scan as a single SARIF 2.1.0 log
+const OutputSARIF = "sarif"
+
+// SARIF rule IDs, one per finding type
+const (
+	sarifRuleLibrary        = "NW001"
+	sarifRuleVulnerable     = "NW002"
+	sarifRuleUnknownVersion = "NW003"
+)
+
+// sarifRules describes the finding types reported by NetWeather, in the
+// order of their ruleIndex
+var sarifRules = []sarifRule{
+	{
+		ID:               sarifRuleLibrary,
+		Name:             "IdentifiedLibrary",
+		ShortDescription: sarifMessage{Text: "JavaScript library identified"},
+		FullDescription:  sarifMessage{Text: "A page loads a JavaScript library that NetWeather identified by name and version."},
+		DefaultConfig:    sarifRuleConfig{Level: "note"},
+		Properties:       map[string]interface{}{"tags": []string{"inventory"}},
+	},
+	{
+		ID:               sarifRuleVulnerable,
+		Name:             "VulnerableLibrary",
+		ShortDescription: sarifMessage{Text: "JavaScript library with known vulnerabilities"},
+		FullDescription:  sarifMessage{Text: "The identified library version is affected by a published advisory. Upgrade to the fixed version."},
+		DefaultConfig:    sarifRuleConfig{Level: "error"},
+		Properties:       map[string]interface{}{"tags": []string{"security", "vulnerable-dependency"}},
+	},
+	{
+		ID:               sarifRuleUnknownVersion,
+		Name:             "UnknownLibraryVersion",
+		ShortDescription: sarifMessage{Text: "JavaScript library version could not be determined"},
+		FullDescription:  sarifMessage{Text: "A library was recognised but its version is unknown, so it cannot be checked for vulnerabilities."},
+		DefaultConfig:    sarifRuleConfig{Level: "warning"},
+		Properties:       map[string]interface{}{"tags": []string{"inventory"}},
+	},
+}
+
+// SARIF 2.1.0 log structure (subset used by NetWeather)
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name"`
+	ShortDescription sarifMessage           `json:"shortDescription"`
+	FullDescription  sarifMessage           `json:"fullDescription"`
+	DefaultConfig    sarifRuleConfig        `json:"defaultConfiguration"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRuleIndex returns the position of a rule in sarifRules
+func sarifRuleIndex(ruleID string) int {
+	for i, rule := range sarifRules {
+		if rule.ID == ruleID {
+			return i
+		}
+	}
+	return -1
+}
+
+// sarifLevel maps an advisory severity to a SARIF result level
+func sarifLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifLocations points a finding at the page and at the script it loads.
+// Inline scripts have no URL of their own and are reported on the page only.
+func sarifLocations(r ScanResult) []sarifLocation {
+	locations := []sarifLocation{{
+		PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: r.URL}},
+		Message:          &sarifMessage{Text: "Page"},
+	}}
+	if r.IsInline {
+		locations[0].Message = &sarifMessage{Text: "Page with inline script " + r.ScriptURL}
+	} else {
+		locations = append(locations, sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: r.ScriptURL}},
+			Message:          &sarifMessage{Text: "Script"},
+		})
+	}
+	return locations
+}
+
+// newSARIFResult creates a finding for a scan result
+func newSARIFResult(ruleID, level, message string, r ScanResult) sarifResult {
+	result := sarifResult{
+		RuleID:    ruleID,
+		RuleIndex: sarifRuleIndex(ruleID),
+		Level:     level,
+		Message:   sarifMessage{Text: message},
+		Locations: sarifLocations(r),
+		PartialFingerprints: map[string]string{
+			"scriptChecksum/v1": r.Checksum,
+		},
+		Properties: map[string]interface{}{
+			"library":      r.LibraryName,
+			"version":      r.LibraryVersion,
+			"identifiedBy": r.IdentifiedBy,
+		},
+	}
+	if r.Owner != "" {
+		result.Properties["owner"] = r.Owner
+	}
+	if len(r.Tags) > 0 {
+		result.Properties["siteTags"] = r.Tags
+	}
+	return result
+}
+
+// buildSARIFResults turns identified libraries into SARIF findings: one per
+// library, one per matching advisory, and one for each unknown version
+func buildSARIFResults(results []ScanResult) []sarifResult {
+	var findings []sarifResult
+	for _, r := range results {
+		if r.LibraryName == "" || strings.EqualFold(r.LibraryName, "unknown") {
+			continue
+		}
+
+		if isComparableVersion(r.LibraryVersion) {
+			findings = append(findings, newSARIFResult(sarifRuleLibrary, "note",
+				fmt.Sprintf("%s %s is loaded by %s", r.LibraryName, r.LibraryVersion, r.URL), r))
+		} else {
+			findings = append(findings, newSARIFResult(sarifRuleUnknownVersion, "warning",
+				fmt.Sprintf("%s is loaded by %s but its version could not be determined", r.LibraryName, r.URL), r))
+		}
+
+		for _, v := range r.Vulnerabilities {
+			ids := strings.Join(v.Identifiers, ", ")
+			message := fmt.Sprintf("%s %s is affected by %s", r.LibraryName, r.LibraryVersion, ids)
+			if v.Severity != "" {
+				message += fmt.Sprintf(" (%s)", v.Severity)
+			}
+			if v.FixedIn != "" {
+				message += fmt.Sprintf(", fixed in %s", v.FixedIn)
+			}
+			if v.Summary != "" {
+				message += ": " + v.Summary
+			}
+
+			finding := newSARIFResult(sarifRuleVulnerable, sarifLevel(v.Severity), message, r)
+			finding.PartialFingerprints["advisory/v1"] = ids
+			finding.Properties["advisories"] = v.Identifiers
+			if v.Severity != "" {
+				finding.Properties["severity"] = v.Severity
+			}
+			if v.FixedIn != "" {
+				finding.Properties["fixedIn"] = v.FixedIn
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// writeSARIF writes a SARIF 2.1.0 log covering the given scan results
+func writeSARIF(w io.Writer, results []ScanResult) error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "NetWeather",
+				InformationURI: "https://github.com/schmalle/netweather",
+				Rules:          sarifRules,
+			}},
+			Results: buildSARIFResults(results),
+		}},
+	}
+	if log.Runs[0].Results == nil {
+		log.Runs[0].Results = []sarifResult{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported SBOM formats
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// SBOMWriter writes one software bill of materials per scanned site
+type SBOMWriter struct {
+	format string
+	dir    string
+	mu     sync.Mutex
+}
+
+// NewSBOMWriter creates a writer for the given format that stores documents in dir
+func NewSBOMWriter(format, dir string) (*SBOMWriter, error) {
+	format = strings.ToLower(format)
+	if format != SBOMFormatCycloneDX && format != SBOMFormatSPDX {
+		return nil, fmt.Errorf("unsupported SBOM format %q (use %s or %s)", format, SBOMFormatCycloneDX, SBOMFormatSPDX)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create SBOM directory %s: %v", dir, err)
+	}
+	return &SBOMWriter{format: format, dir: dir}, nil
+}
+
+// sbomComponent is a library found on a site, merged across all script
+// locations that serve the same content
+type sbomComponent struct {
+	Name            string
+	Version         string
+	Checksum        string
+	IdentifiedBy    string
+	PURL            string
+	Locations       []string
+	Vulnerabilities []Vulnerability
+}
+
+// Write renders the SBOM for a single site and stores it in the output directory
+func (w *SBOMWriter) Write(baseURL string, results []ScanResult) (string, error) {
+	components := buildSBOMComponents(results)
+
+	var doc interface{}
+	ext := ".cdx.json"
+	if w.format == SBOMFormatSPDX {
+		doc = buildSPDXDocument(baseURL, components)
+		ext = ".spdx.json"
+	} else {
+		doc = buildCycloneDXDocument(baseURL, components)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	path := filepath.Join(w.dir, sbomFileName(baseURL)+ext)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	logger.Printf("Wrote %s SBOM for %s to %s\n", w.format, baseURL, path)
+	return path, nil
+}
+
+// buildSBOMComponents groups scan results by library, version and checksum
+func buildSBOMComponents(results []ScanResult) []*sbomComponent {
+	var components []*sbomComponent
+	index := make(map[string]*sbomComponent)
+
+	for _, r := range results {
+		key := r.LibraryName + "|" + r.LibraryVersion + "|" + r.Checksum
+		if c, exists := index[key]; exists {
+			c.Locations = append(c.Locations, r.ScriptURL)
+			continue
+		}
+		c := &sbomComponent{
+			Name:            r.LibraryName,
+			Version:         sbomVersion(r.LibraryVersion),
+			Checksum:        r.Checksum,
+			IdentifiedBy:    r.IdentifiedBy,
+			Locations:       []string{r.ScriptURL},
+			Vulnerabilities: r.Vulnerabilities,
+		}
+		if r.IdentifiedBy != "unknown" {
+			c.PURL = npmPURL(vulnDB.NpmName(r.LibraryName), c.Version)
+		}
+		index[key] = c
+		components = append(components, c)
+	}
+	return components
+}
+
+// sbomVersion drops placeholder versions that are not real release numbers
+func sbomVersion(version string) string {
+	if !isComparableVersion(version) {
+		return ""
+	}
+	return version
+}
+
+// npmPURL builds a package URL such as pkg:npm/jquery@3.7.1 from an npm
+// package name. Libraries are only given one if the advisory file names
+// their npm package; other names need not exist on npm.
+func npmPURL(name, version string) string {
+	if name == "" {
+		return ""
+	}
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "@") {
+		name = "%40" + name[1:]
+	}
+	purl := "pkg:npm/" + name
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+	return purl
+}
+
+var sbomFileNameCleaner = regexp.MustCompile(`[^a-zA-Z0-9\.\-]+`)
+
+// sbomFileName derives a file-system safe name from a site URL. The
+// readable part loses the scheme and special characters, so a short hash
+// of the full URL keeps the names of different sites apart.
+func sbomFileName(baseURL string) string {
+	name := baseURL
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+		name = parsed.Host + parsed.Path
+	}
+	name = strings.Trim(sbomFileNameCleaner.ReplaceAllString(name, "_"), "_.")
+	if name == "" {
+		name = "site"
+	}
+	hash := sha256.Sum256([]byte(baseURL))
+	return name + "-" + hex.EncodeToString(hash[:4])
+}
+
+// identificationTechnique maps our identification methods to CycloneDX evidence
+// techniques and a confidence value
+func identificationTechnique(method string) (string, float64) {
+	switch method {
+	case "checksum-db", "file-db", "local-db", "publicdata-api":
+		return "hash-comparison", 1.0
+	case "url-pattern":
+		return "filename", 0.8
+	case "context-analysis":
+		return "source-code-analysis", 0.7
+	case "code-analysis":
+		return "source-code-analysis", 0.6
+	case "signature-analysis":
+		return "source-code-analysis", 0.4
+	}
+	return "other", 0.1
+}
+
+// CycloneDX 1.5 document structure (subset used by NetWeather)
+type cdxDocument struct {
+	BOMFormat       string             `json:"bomFormat"`
+	SpecVersion     string             `json:"specVersion"`
+	SerialNumber    string             `json:"serialNumber"`
+	Version         int                `json:"version"`
+	Metadata        cdxMetadata        `json:"metadata"`
+	Components      []cdxComponent     `json:"components"`
+	Dependencies    []cdxDependency    `json:"dependencies,omitempty"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Evidence   *cdxEvidence  `json:"evidence,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxEvidence struct {
+	Identity    *cdxIdentity    `json:"identity,omitempty"`
+	Occurrences []cdxOccurrence `json:"occurrences,omitempty"`
+}
+
+type cdxIdentity struct {
+	Field      string      `json:"field"`
+	Confidence float64     `json:"confidence"`
+	Methods    []cdxMethod `json:"methods"`
+}
+
+type cdxMethod struct {
+	Technique  string  `json:"technique"`
+	Confidence float64 `json:"confidence"`
+	Value      string  `json:"value"`
+}
+
+type cdxOccurrence struct {
+	Location string `json:"location"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxVulnerability struct {
+	ID             string       `json:"id"`
+	Description    string       `json:"description,omitempty"`
+	Ratings        []cdxRating  `json:"ratings,omitempty"`
+	Recommendation string       `json:"recommendation,omitempty"`
+	Affects        []cdxAffects `json:"affects"`
+}
+
+type cdxRating struct {
+	Severity string `json:"severity"`
+}
+
+type cdxAffects struct {
+	Ref string `json:"ref"`
+}
+
+// buildCycloneDXDocument renders a CycloneDX 1.5 BOM for a site
+func buildCycloneDXDocument(baseURL string, components []*sbomComponent) cdxDocument {
+	siteRef := "site"
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: "netweather"},
+			}},
+			Component: cdxComponent{Type: "application", BOMRef: siteRef, Name: baseURL},
+		},
+		Components: []cdxComponent{},
+	}
+
+	// An advisory is listed once, affecting every component it applies to
+	vulnerabilities := make(map[string]int)
+	dependency := cdxDependency{Ref: siteRef, DependsOn: []string{}}
+	for i, c := range components {
+		ref := fmt.Sprintf("component-%d", i+1)
+		technique, confidence := identificationTechnique(c.IdentifiedBy)
+
+		component := cdxComponent{
+			Type:    "library",
+			BOMRef:  ref,
+			Name:    c.Name,
+			Version: c.Version,
+			PURL:    c.PURL,
+			Hashes:  []cdxHash{{Alg: "SHA-256", Content: c.Checksum}},
+			Evidence: &cdxEvidence{
+				Identity: &cdxIdentity{
+					Field:      "purl",
+					Confidence: confidence,
+					Methods:    []cdxMethod{{Technique: technique, Confidence: confidence, Value: c.IdentifiedBy}},
+				},
+			},
+			Properties: []cdxProperty{{Name: "netweather:identified_by", Value: c.IdentifiedBy}},
+		}
+		if c.PURL == "" {
+			component.Evidence.Identity.Field = "name"
+		}
+		for _, location := range c.Locations {
+			component.Evidence.Occurrences = append(component.Evidence.Occurrences, cdxOccurrence{Location: location})
+		}
+		doc.Components = append(doc.Components, component)
+		dependency.DependsOn = append(dependency.DependsOn, ref)
+
+		for _, v := range c.Vulnerabilities {
+			for _, id := range v.Identifiers {
+				if index, exists := vulnerabilities[id]; exists {
+					vuln := &doc.Vulnerabilities[index]
+					if !hasAffectedRef(vuln.Affects, ref) {
+						vuln.Affects = append(vuln.Affects, cdxAffects{Ref: ref})
+					}
+					continue
+				}
+				vuln := cdxVulnerability{
+					ID:          id,
+					Description: v.Summary,
+					Affects:     []cdxAffects{{Ref: ref}},
+				}
+				if v.Severity != "" {
+					vuln.Ratings = []cdxRating{{Severity: v.Severity}}
+				}
+				if v.FixedIn != "" {
+					vuln.Recommendation = fmt.Sprintf("Upgrade %s to %s or later", c.Name, v.FixedIn)
+				}
+				vulnerabilities[id] = len(doc.Vulnerabilities)
+				doc.Vulnerabilities = append(doc.Vulnerabilities, vuln)
+			}
+		}
+	}
+	doc.Dependencies = []cdxDependency{dependency}
+
+	return doc
+}
+
+// hasAffectedRef reports whether a vulnerability already affects a component
+func hasAffectedRef(affects []cdxAffects, ref string) bool {
+	for _, a := range affects {
+		if a.Ref == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// SPDX 2.3 document structure (subset used by NetWeather)
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// buildSPDXDocument renders an SPDX 2.3 document for a site
+func buildSPDXDocument(baseURL string, components []*sbomComponent) spdxDocument {
+	siteID := "SPDXRef-Site"
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "netweather-" + sbomFileName(baseURL),
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/netweather-%s-%s", sbomFileName(baseURL), uuid.New().String()),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: netweather"},
+		},
+		Packages: []spdxPackage{{
+			Name:                  baseURL,
+			SPDXID:                siteID,
+			DownloadLocation:      baseURL,
+			FilesAnalyzed:         false,
+			LicenseConcluded:      "NOASSERTION",
+			LicenseDeclared:       "NOASSERTION",
+			CopyrightText:         "NOASSERTION",
+			PrimaryPackagePurpose: "APPLICATION",
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: siteID,
+		}},
+	}
+
+	for i, c := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+
+		// Inline scripts have no location that can be downloaded
+		downloadLocation := "NOASSERTION"
+		if strings.HasPrefix(c.Locations[0], "http://") || strings.HasPrefix(c.Locations[0], "https://") {
+			downloadLocation = c.Locations[0]
+		}
+
+		pkg := spdxPackage{
+			Name:             c.Name,
+			SPDXID:           id,
+			VersionInfo:      c.Version,
+			DownloadLocation: downloadLocation,
+			FilesAnalyzed:    false,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.Checksum}},
+			Comment:          fmt.Sprintf("Identified by %s at %s", c.IdentifiedBy, strings.Join(c.Locations, ", ")),
+		}
+		if c.PURL != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.PURL,
+			}}
+		}
+		for _, v := range c.Vulnerabilities {
+			for _, id := range v.Identifiers {
+				pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{
+					ReferenceCategory: "SECURITY",
+					ReferenceType:     "advisory",
+					ReferenceLocator:  advisoryURL(id),
+				})
+			}
+		}
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      siteID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	return doc
+}
+
+// advisoryURL returns a reference URL for a CVE or GitHub advisory identifier
+func advisoryURL(id string) string {
+	if strings.HasPrefix(id, "GHSA-") {
+		return "https://github.com/advisories/" + id
+	}
+	return "https://nvd.nist.gov/vuln/detail/" + id
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSBOMFileName(t *testing.T) {
+	urls := []string{
+		"http://example.com/",
+		"https://example.com/",
+		"https://example.com/a/b",
+		"https://example.com/a_b",
+		"https://example.com/a?b",
+	}
+	valid := regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	names := make(map[string]string)
+	for _, u := range urls {
+		name := sbomFileName(u)
+		if !valid.MatchString(name) {
+			t.Errorf("sbomFileName(%q) = %q, not a safe file name", u, name)
+		}
+		if other, exists := names[name]; exists {
+			t.Errorf("sbomFileName(%q) = sbomFileName(%q) = %q", u, other, name)
+		}
+		names[name] = u
+	}
+	if sbomFileName(urls[0]) != sbomFileName(urls[0]) {
+		t.Error("sbomFileName is not stable")
+	}
+}
+
+func TestBuildSBOMComponentsPURL(t *testing.T) {
+	saved := vulnDB
+	vulnDB = newTestVulnerabilityDB(t)
+	defer func() { vulnDB = saved }()
+
+	results := []ScanResult{
+		{ScriptURL: "https://cdn.example/jquery.min.js", LibraryName: "jquery", LibraryVersion: "3.7.1", Checksum: "a", IdentifiedBy: "url-pattern"},
+		{ScriptURL: "https://cdn.example/moment.js", LibraryName: "moment.js", LibraryVersion: "2.29.4", Checksum: "b", IdentifiedBy: "checksum-db"},
+		{ScriptURL: "https://site.example/widgets.js", LibraryName: "widgets", LibraryVersion: "1.0.0", Checksum: "c", IdentifiedBy: "code-analysis"},
+		{ScriptURL: "https://site.example/app.js", LibraryName: "jquery", LibraryVersion: "unknown", Checksum: "d", IdentifiedBy: "unknown"},
+	}
+	want := []string{"pkg:npm/jquery@3.7.1", "pkg:npm/moment@2.29.4", "", ""}
+
+	components := buildSBOMComponents(results)
+	if len(components) != len(want) {
+		t.Fatalf("got %d components, want %d", len(components), len(want))
+	}
+	for i, c := range components {
+		if c.PURL != want[i] {
+			t.Errorf("PURL of %s = %q, want %q", c.Name, c.PURL, want[i])
+		}
+	}
+}
+
+func TestCycloneDXMergesVulnerabilities(t *testing.T) {
+	xss := Vulnerability{Identifiers: []string{"CVE-2020-11022"}, Severity: "medium", FixedIn: "3.5.0"}
+	proto := Vulnerability{Identifiers: []string{"CVE-2019-11358"}, Severity: "medium", FixedIn: "3.4.0"}
+	components := []*sbomComponent{
+		{Name: "jquery", Version: "3.3.1", Checksum: "a", Vulnerabilities: []Vulnerability{xss, proto}},
+		{Name: "jquery", Version: "3.4.1", Checksum: "b", Vulnerabilities: []Vulnerability{xss, xss}},
+	}
+
+	doc := buildCycloneDXDocument("https://example.com/", components)
+	want := map[string][]string{
+		"CVE-2020-11022": {"component-1", "component-2"},
+		"CVE-2019-11358": {"component-1"},
+	}
+	if len(doc.Vulnerabilities) != len(want) {
+		t.Fatalf("got %d vulnerabilities, want %d", len(doc.Vulnerabilities), len(want))
+	}
+	for _, v := range doc.Vulnerabilities {
+		var refs []string
+		for _, a := range v.Affects {
+			refs = append(refs, a.Ref)
+		}
+		if !reflect.DeepEqual(refs, want[v.ID]) {
+			t.Errorf("%s affects %q, want %q", v.ID, refs, want[v.ID])
+		}
+	}
+}
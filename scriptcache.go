@@ -0,0 +1,355 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultScriptCacheMB is the memory budget of the script cache in megabytes
+const defaultScriptCacheMB = 64
+
+// Eviction of the cache directory: files not used for scriptCacheMaxAge are
+// removed at most once per scriptCachePruneInterval
+const (
+	scriptCacheMaxAge        = 30 * 24 * time.Hour
+	scriptCachePruneInterval = 24 * time.Hour
+)
+
+// scriptCacheEntry is a downloaded script together with how long it may be
+// used without asking the server and the validators needed to revalidate it
+type scriptCacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FreshUntil   time.Time `json:"fresh_until,omitzero"`
+	Checksum     string    `json:"checksum"`
+	Content      string    `json:"content,omitempty"` // On disk in a file of its own, named by checksum
+}
+
+// Fresh reports whether the entry may be used without a request
+func (e *scriptCacheEntry) Fresh() bool {
+	return time.Now().Before(e.FreshUntil)
+}
+
+// Revalidatable reports whether the entry can be checked with a
+// conditional request
+func (e *scriptCacheEntry) Revalidatable() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// scriptContent is script content shared by the entries with its checksum
+type scriptContent struct {
+	content string
+	refs    int
+}
+
+// scriptCache keeps downloaded scripts by absolute URL and their content by
+// checksum, so that a script included by many sites is downloaded once and
+// the same file served from several URLs is held once. Entries still fresh
+// by their Cache-Control or Expires header are used without a request,
+// others are revalidated. Entries are evicted least recently used first once
+// their content exceeds maxBytes. With a directory, entries are also written
+// to disk and survive between runs until they have not been used for
+// scriptCacheMaxAge.
+type scriptCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	dir      string
+	size     int64
+	order    *list.List                // Most recently used first
+	entries  map[string]*list.Element  // By URL, values are *scriptCacheEntry
+	contents map[string]*scriptContent // By checksum
+	pruned   time.Time                 // Last eviction pass over dir
+}
+
+// scripts is the shared script cache; nil disables caching
+var scripts = newScriptCache(defaultScriptCacheMB*1024*1024, "")
+
+// newScriptCache creates a cache holding up to maxBytes of script content,
+// persisted to dir unless it is empty
+func newScriptCache(maxBytes int64, dir string) *scriptCache {
+	return &scriptCache{
+		maxBytes: maxBytes,
+		dir:      dir,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		contents: make(map[string]*scriptContent),
+	}
+}
+
+// configureScriptCache replaces the shared cache. A size of 0 disables the
+// memory cache; the directory is created if needed.
+func configureScriptCache(sizeMB int, dir string) error {
+	if sizeMB < 0 {
+		return fmt.Errorf("script cache size must not be negative")
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating script cache directory: %v", err)
+		}
+	}
+	if sizeMB == 0 && dir == "" {
+		scripts = nil
+		return nil
+	}
+	scripts = newScriptCache(int64(sizeMB)*1024*1024, dir)
+	if dir != "" {
+		scripts.maybePrune()
+	}
+	return nil
+}
+
+// Get returns the cached entry of a script URL, loading it from disk if it
+// is not held in memory
+func (c *scriptCache) Get(scriptURL string) *scriptCacheEntry {
+	c.mu.Lock()
+	if element, ok := c.entries[scriptURL]; ok {
+		c.order.MoveToFront(element)
+		entry := element.Value.(*scriptCacheEntry)
+		c.mu.Unlock()
+		c.touch(entry)
+		return entry
+	}
+	c.mu.Unlock()
+
+	entry := c.load(scriptURL)
+	if entry != nil {
+		c.remember(entry)
+	}
+	return entry
+}
+
+// Put stores a downloaded script in memory and, with a directory, on disk
+func (c *scriptCache) Put(entry *scriptCacheEntry) {
+	c.remember(entry)
+	if c.dir == "" {
+		return
+	}
+	if err := c.save(entry); err != nil {
+		logger.Printf("Error writing script cache entry for %s: %v\n", entry.URL, err)
+	}
+	c.maybePrune()
+}
+
+// remember adds an entry to the memory cache, evicting the least recently
+// used entries beyond the size limit. Content is counted once per checksum.
+func (c *scriptCache) remember(entry *scriptCacheEntry) {
+	if int64(len(entry.Content)) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.URL]; ok {
+		c.release(c.order.Remove(element).(*scriptCacheEntry))
+	}
+	if shared, ok := c.contents[entry.Checksum]; ok {
+		shared.refs++
+		entry.Content = shared.content
+	} else {
+		c.contents[entry.Checksum] = &scriptContent{content: entry.Content, refs: 1}
+		c.size += int64(len(entry.Content))
+	}
+	c.entries[entry.URL] = c.order.PushFront(entry)
+
+	for c.size > c.maxBytes {
+		evicted := c.order.Remove(c.order.Back()).(*scriptCacheEntry)
+		delete(c.entries, evicted.URL)
+		c.release(evicted)
+	}
+}
+
+// release drops the reference of an entry to its content, freeing the
+// content once no entry uses it. The caller holds c.mu.
+func (c *scriptCache) release(entry *scriptCacheEntry) {
+	shared, ok := c.contents[entry.Checksum]
+	if !ok {
+		return
+	}
+	if shared.refs--; shared.refs == 0 {
+		delete(c.contents, entry.Checksum)
+		c.size -= int64(len(shared.content))
+	}
+}
+
+// path returns the file of a script URL in the cache directory
+func (c *scriptCache) path(scriptURL string) string {
+	hash := sha256.Sum256([]byte(scriptURL))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// contentPath returns the file of script content in the cache directory
+func (c *scriptCache) contentPath(checksum string) string {
+	return filepath.Join(c.dir, checksum+".js")
+}
+
+// load reads the entry of a script URL and its content from the cache
+// directory. Entries whose content no longer matches their checksum are
+// ignored.
+func (c *scriptCache) load(scriptURL string) *scriptCacheEntry {
+	if c.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.path(scriptURL))
+	if err != nil {
+		return nil
+	}
+	var entry scriptCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != scriptURL || !isHexChecksum(entry.Checksum) {
+		logger.Printf("Ignoring invalid script cache entry for %s\n", scriptURL)
+		return nil
+	}
+	// Files written before content was stored by checksum hold it inline
+	if entry.Content == "" {
+		content, err := os.ReadFile(c.contentPath(entry.Checksum))
+		if err != nil {
+			logger.Printf("Ignoring script cache entry for %s without content: %v\n", scriptURL, err)
+			return nil
+		}
+		entry.Content = string(content)
+	}
+	hash := sha256.Sum256([]byte(entry.Content))
+	if hex.EncodeToString(hash[:]) != entry.Checksum {
+		logger.Printf("Ignoring corrupt script cache entry for %s\n", scriptURL)
+		return nil
+	}
+	c.touch(&entry)
+	return &entry
+}
+
+// isHexChecksum reports whether s is a hex encoded SHA-256 checksum
+func isHexChecksum(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// touch marks the files of an entry and of its content as used, so
+// maybePrune keeps them
+func (c *scriptCache) touch(entry *scriptCacheEntry) {
+	if c.dir == "" {
+		return
+	}
+	now := time.Now()
+	os.Chtimes(c.path(entry.URL), now, now)
+	os.Chtimes(c.contentPath(entry.Checksum), now, now)
+}
+
+// save writes an entry to the cache directory: the content once per
+// checksum, the entry itself replacing the previous file of its URL
+func (c *scriptCache) save(entry *scriptCacheEntry) error {
+	contentPath := c.contentPath(entry.Checksum)
+	if _, err := os.Stat(contentPath); err != nil {
+		if err := c.writeFile(contentPath, []byte(entry.Content)); err != nil {
+			return err
+		}
+	} else {
+		now := time.Now()
+		os.Chtimes(contentPath, now, now)
+	}
+
+	stored := *entry
+	stored.Content = ""
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(c.path(entry.URL), data)
+}
+
+// writeFile replaces a file of the cache directory atomically
+func (c *scriptCache) writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(c.dir, "script-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// scriptFreshUntil returns until when a response may be used without
+// revalidation according to its Cache-Control max-age or Expires header,
+// the zero time if it must be revalidated. It reports false for responses
+// that must not be stored at all.
+func scriptFreshUntil(header http.Header, now time.Time) (time.Time, bool) {
+	maxAge := -1
+	for _, directive := range strings.Split(strings.ToLower(header.Get("Cache-Control")), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch name {
+		case "no-store":
+			return time.Time{}, false
+		case "no-cache":
+			return time.Time{}, true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				maxAge = seconds
+			}
+		}
+	}
+	if maxAge >= 0 {
+		return now.Add(time.Duration(maxAge) * time.Second), true
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil && expires.After(now) {
+		return expires, true
+	}
+	return time.Time{}, true
+}
+
+// maybePrune removes the files of the cache directory that were not used
+// for scriptCacheMaxAge, unless that was done less than
+// scriptCachePruneInterval ago
+func (c *scriptCache) maybePrune() {
+	c.mu.Lock()
+	due := time.Since(c.pruned) >= scriptCachePruneInterval
+	if due {
+		c.pruned = time.Now()
+	}
+	c.mu.Unlock()
+	if !due {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		logger.Printf("Error reading script cache directory: %v\n", err)
+		return
+	}
+	cutoff := time.Now().Add(-scriptCacheMaxAge)
+	removed := 0
+	for _, dirEntry := range entries {
+		name := dirEntry.Name()
+		isEntry := (len(name) == sha256.Size*2+len(".json") && strings.HasSuffix(name, ".json")) ||
+			(len(name) == sha256.Size*2+len(".js") && strings.HasSuffix(name, ".js"))
+		isTemp := strings.HasPrefix(name, "script-") && strings.HasSuffix(name, ".tmp")
+		if dirEntry.IsDir() || !(isEntry || isTemp) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err == nil {
+			removed++
+		}
+	}
+	if removed > 0 {
+		logger.Printf("Removed %d script cache files unused for %d days\n", removed, int(scriptCacheMaxAge.Hours()/24))
+	}
+}
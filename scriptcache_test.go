@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestScriptEntry(url, content string) *scriptCacheEntry {
+	hash := sha256.Sum256([]byte(content))
+	return &scriptCacheEntry{URL: url, ETag: `"v1"`, Checksum: hex.EncodeToString(hash[:]), Content: content}
+}
+
+func TestScriptFreshUntil(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		cacheControl string
+		expires      string
+		want         time.Time
+		wantStorable bool
+	}{
+		{"no headers", "", "", time.Time{}, true},
+		{"max-age", "public, max-age=3600", "", now.Add(time.Hour), true},
+		{"max-age over expires", "max-age=60", "Thu, 01 Jan 2026 13:00:00 GMT", now.Add(time.Minute), true},
+		{"expires", "", "Thu, 01 Jan 2026 13:00:00 GMT", now.Add(time.Hour), true},
+		{"expired", "", "Thu, 01 Jan 2026 11:00:00 GMT", time.Time{}, true},
+		{"no-cache", "no-cache, max-age=3600", "", time.Time{}, true},
+		{"no-store", "no-store", "", time.Time{}, false},
+		{"invalid max-age", "max-age=soon", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.cacheControl != "" {
+			header.Set("Cache-Control", tt.cacheControl)
+		}
+		if tt.expires != "" {
+			header.Set("Expires", tt.expires)
+		}
+		got, storable := scriptFreshUntil(header, now)
+		if !got.Equal(tt.want) || storable != tt.wantStorable {
+			t.Errorf("%s: scriptFreshUntil = %v, %v, want %v, %v", tt.name, got, storable, tt.want, tt.wantStorable)
+		}
+	}
+}
+
+func TestScriptCacheSharesContentByChecksum(t *testing.T) {
+	cache := newScriptCache(10, "")
+	cache.Put(newTestScriptEntry("https://a.example/jquery.js", "jquery"))
+	cache.Put(newTestScriptEntry("https://b.example/jquery.js", "jquery"))
+	if cache.size != 6 {
+		t.Errorf("size = %d after caching the same content twice, want 6", cache.size)
+	}
+
+	// Evicts the least recently used URL; its content stays for the other
+	cache.Put(newTestScriptEntry("https://a.example/app.js", "app!"))
+	if cache.size != 10 || cache.Get("https://a.example/jquery.js") == nil {
+		t.Errorf("size = %d, want 10 with both contents cached", cache.size)
+	}
+	cache.Put(newTestScriptEntry("https://c.example/x.js", "x"))
+	if cache.Get("https://b.example/jquery.js") != nil {
+		t.Error("least recently used entry was not evicted")
+	}
+	if entry := cache.Get("https://a.example/jquery.js"); entry == nil || entry.Content != "jquery" {
+		t.Errorf("Get of the entry sharing evicted content = %+v", entry)
+	}
+}
+
+func TestScriptCacheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cache := newScriptCache(1024, dir)
+	first := newTestScriptEntry("https://a.example/jquery.js", "jquery")
+	first.FreshUntil = time.Now().Add(time.Hour).Round(time.Second)
+	cache.Put(first)
+	cache.Put(newTestScriptEntry("https://b.example/jquery.js", "jquery"))
+
+	contents, _ := filepath.Glob(filepath.Join(dir, "*.js"))
+	if len(contents) != 1 {
+		t.Errorf("content files = %q, want one shared file", contents)
+	}
+
+	// A new cache reads the entries back from the directory
+	reloaded := newScriptCache(1024, dir)
+	entry := reloaded.Get("https://a.example/jquery.js")
+	if entry == nil || entry.Content != "jquery" || !entry.Fresh() || !entry.FreshUntil.Equal(first.FreshUntil) {
+		t.Fatalf("reloaded entry = %+v", entry)
+	}
+
+	// Changed content does not match the checksum and is ignored
+	if err := os.WriteFile(contents[0], []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if entry := newScriptCache(1024, dir).Get("https://b.example/jquery.js"); entry != nil {
+		t.Errorf("entry with changed content = %+v, want nil", entry)
+	}
+}
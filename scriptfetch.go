@@ -0,0 +1,80 @@
+package main
+
+import "sync"
+
+// Defaults of the script fetch limits
+const (
+	defaultPageScriptWorkers = 4  // Scripts of one page fetched at once
+	defaultScriptFetchLimit  = 32 // Script downloads in flight across all pages
+)
+
+var (
+	// pageScriptWorkers bounds the scripts of a page inspected at once
+	pageScriptWorkers = defaultPageScriptWorkers
+
+	// scriptFetchSlots holds a token for every script download in flight
+	scriptFetchSlots = make(chan struct{}, defaultScriptFetchLimit)
+)
+
+// setScriptConcurrency sets the per-page and global script fetch limits.
+// It must be called before scanning starts; values below 1 keep the
+// defaults.
+func setScriptConcurrency(perPage, inFlight int) {
+	if perPage > 0 {
+		pageScriptWorkers = perPage
+	}
+	if inFlight > 0 {
+		scriptFetchSlots = make(chan struct{}, inFlight)
+	}
+}
+
+// acquireScriptFetch blocks until a script download may start; the
+// returned function ends it
+func acquireScriptFetch() func() {
+	slots := scriptFetchSlots
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// scriptOutcome is the result of inspecting one script of a page
+type scriptOutcome struct {
+	Script pageScript
+	Result *ScanResult // nil for inline scripts without a recognised library
+	Err    error
+}
+
+// inspectPageScripts inspects the scripts of a page with up to
+// pageScriptWorkers at once and returns the outcomes in document order
+func inspectPageScripts(pageURL string, scripts []pageScript) []scriptOutcome {
+	outcomes := make([]scriptOutcome, len(scripts))
+	workers := pageScriptWorkers
+	if workers > len(scripts) {
+		workers = len(scripts)
+	}
+	if workers <= 1 {
+		for i, script := range scripts {
+			result, err := inspectScript(pageURL, script)
+			outcomes[i] = scriptOutcome{Script: script, Result: result, Err: err}
+		}
+		return outcomes
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result, err := inspectScript(pageURL, scripts[index])
+				outcomes[index] = scriptOutcome{Script: scripts[index], Result: result, Err: err}
+			}
+		}()
+	}
+	for i := range scripts {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return outcomes
+}
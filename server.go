@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// States of a scan job submitted to the API
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// apiJobTimeout bounds the processing time of a single scan job
+const apiJobTimeout = 30 * time.Minute
+
+// apiMaxBodySize limits the size of a submitted URL list
+const apiMaxBodySize = 10 << 20
+
+// apiMaxFinishedJobs is the number of finished jobs kept for status and
+// result queries; older ones are forgotten when new jobs are submitted
+const apiMaxFinishedJobs = 100
+
+// defaultListenAddr is the address of the serve API and the daemon health
+// endpoint. The API has no authentication, so it only listens on loopback
+// unless -listen says otherwise.
+const defaultListenAddr = "127.0.0.1:8090"
+
+// ServeConfig holds the settings of "netweather serve"
+type ServeConfig struct {
+	Listen        string
+	MaxWorkers    int
+	RequestDelay  time.Duration
+	BatchSize     int
+	UseDB         bool
+	DiscoverPages int         // Pages per host to add from sitemaps, 0 to disable discovery
+	Crawl         CrawlConfig // Same-origin pages to scan beyond each URL
+}
+
+// ScanJob is a URL list submitted to the API and processed by a
+// ParallelProcessor
+type ScanJob struct {
+	ID         string
+	URLs       []URLJob
+	State      string
+	Error      string
+	RunID      int64
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+
+	processor *ParallelProcessor
+	results   []URLResult
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+}
+
+// jobStatus is the API representation of a scan job
+type jobStatus struct {
+	ID         string           `json:"id"`
+	State      string           `json:"state"`
+	Error      string           `json:"error,omitempty"`
+	RunID      int64            `json:"run_id,omitempty"`
+	Total      int              `json:"total"`
+	Processed  int64            `json:"processed"`
+	Scanned    int64            `json:"scanned"`
+	Excluded   int64            `json:"excluded"`
+	ExcludedBy map[string]int64 `json:"excluded_by_rule,omitempty"`
+	Skipped    int64            `json:"skipped"`
+	Errors     int64            `json:"errors"`
+	Dropped    int64            `json:"dropped_rows,omitempty"` // Database rows that could not be stored
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// libraryRecord is the API representation of a LibraryUsage row
+type libraryRecord struct {
+	Name         string `json:"name"`
+	Version      string `json:"version,omitempty"`
+	Checksum     string `json:"checksum"`
+	Count        int    `json:"count"`
+	IdentifiedBy string `json:"identified_by"`
+}
+
+// scanRequest is the JSON body accepted when submitting a scan job
+type scanRequest struct {
+	URLs json.RawMessage `json:"urls"` // URL strings or {"url", "owner", "tags"} objects
+}
+
+// Status returns a snapshot of the job, with counts taken from the
+// processor's ProgressTracker while the job runs
+func (j *ScanJob) Status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := jobStatus{
+		ID:         j.ID,
+		State:      j.State,
+		Error:      j.Error,
+		RunID:      j.RunID,
+		Total:      len(j.URLs),
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+	if j.processor != nil {
+		status.Processed, status.Scanned, status.Excluded, status.Skipped, status.Errors = j.processor.Counts()
+		status.ExcludedBy = j.processor.ExcludedByRule()
+		status.Dropped = j.processor.DroppedRows()
+	}
+	return status
+}
+
+// Results returns the records of the URLs processed so far
+func (j *ScanJob) Results() []urlRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	records := make([]urlRecord, 0, len(j.results))
+	for _, result := range j.results {
+		records = append(records, newURLRecord(result))
+	}
+	return records
+}
+
+// addResult collects the outcome of a processed URL
+func (j *ScanJob) addResult(result URLResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results = append(j.results, result)
+}
+
+// finished reports whether the job has ended
+func (j *ScanJob) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.FinishedAt != nil
+}
+
+// finish records the final state of the job
+func (j *ScanJob) finish(state string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	finishedAt := time.Now()
+	j.FinishedAt = &finishedAt
+	j.State = state
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// APIServer runs submitted scan jobs one at a time and serves their status
+// and results over HTTP
+type APIServer struct {
+	config ServeConfig
+	jobs   map[string]*ScanJob
+	order  []string // Job IDs in submission order
+	queue  chan *ScanJob
+	mu     sync.RWMutex
+}
+
+// NewAPIServer creates an API server
+func NewAPIServer(config ServeConfig) *APIServer {
+	return &APIServer{
+		config: config,
+		jobs:   make(map[string]*ScanJob),
+		queue:  make(chan *ScanJob, 100),
+	}
+}
+
+// Router returns the HTTP routes of the API and the web dashboard
+func (s *APIServer) Router() (*mux.Router, error) {
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/health", s.healthHandler).Methods("GET")
+	api.HandleFunc("/scans", s.createScanHandler).Methods("POST")
+	api.HandleFunc("/scans", s.listScansHandler).Methods("GET")
+	api.HandleFunc("/scans/{id}", s.getScanHandler).Methods("GET")
+	api.HandleFunc("/scans/{id}", s.cancelScanHandler).Methods("DELETE")
+	api.HandleFunc("/scans/{id}/results", s.getScanResultsHandler).Methods("GET")
+	api.HandleFunc("/statistics/libraries", s.libraryStatisticsHandler).Methods("GET")
+
+	if err := registerDashboard(r); err != nil {
+		return nil, fmt.Errorf("error loading dashboard: %v", err)
+	}
+	return r, nil
+}
+
+// Submit queues a scan job for the given URLs
+func (s *APIServer) Submit(urls []URLJob) (*ScanJob, error) {
+	job := &ScanJob{
+		ID:        uuid.New().String(),
+		URLs:      urls,
+		State:     JobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.queue <- job:
+	default:
+		return nil, fmt.Errorf("too many queued scan jobs")
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.pruneJobs()
+	logger.Printf("Queued scan job %s with %d URLs\n", job.ID, len(urls))
+	return job, nil
+}
+
+// pruneJobs forgets the oldest finished jobs beyond apiMaxFinishedJobs.
+// The caller must hold s.mu.
+func (s *APIServer) pruneJobs() {
+	finished := 0
+	for _, id := range s.order {
+		if s.jobs[id].finished() {
+			finished++
+		}
+	}
+	if finished <= apiMaxFinishedJobs {
+		return
+	}
+
+	// Drop finished jobs from the oldest on until the limit is met
+	excess := finished - apiMaxFinishedJobs
+	order := make([]string, 0, len(s.order))
+	for _, id := range s.order {
+		if excess > 0 && s.jobs[id].finished() {
+			delete(s.jobs, id)
+			excess--
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+}
+
+// Job returns a submitted job by ID
+func (s *APIServer) Job(id string) *ScanJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jobs[id]
+}
+
+// runJobs processes queued jobs until the context is cancelled
+func (s *APIServer) runJobs(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+// runJob processes the URLs of a job, recording it as a scan run when the
+// database is enabled
+func (s *APIServer) runJob(parent context.Context, job *ScanJob) {
+	ctx, cancel := context.WithTimeout(parent, apiJobTimeout)
+	defer cancel()
+
+	job.mu.Lock()
+	if job.State == JobCancelled {
+		job.mu.Unlock()
+		return
+	}
+	startedAt := time.Now()
+	job.StartedAt = &startedAt
+	job.State = JobRunning
+	job.cancel = cancel
+	job.mu.Unlock()
+	logger.Printf("Starting scan job %s\n", job.ID)
+
+	if s.config.DiscoverPages > 0 {
+		urls := discoverPages(ctx, job.URLs, s.config.DiscoverPages, s.config.MaxWorkers)
+		job.mu.Lock()
+		job.URLs = urls
+		job.mu.Unlock()
+	}
+
+	var run *ScanRun
+	if s.config.UseDB {
+		run = &ScanRun{
+			StartedAt: startedAt,
+			InputFile: "api:" + job.ID,
+			Flags:     explicitFlags(),
+			Workers:   s.config.MaxWorkers,
+			TotalURLs: int64(len(job.URLs)),
+		}
+		if err := startScanRun(run); err != nil {
+			logger.Printf("Error recording scan run for job %s: %v\n", job.ID, err)
+			run = nil
+		}
+	}
+
+	config := ParallelConfig{
+		MaxWorkers:   s.config.MaxWorkers,
+		RequestDelay: s.config.RequestDelay,
+		BatchSize:    s.config.BatchSize,
+		UseDB:        s.config.UseDB,
+		OnResult:     job.addResult,
+		Quiet:        true,
+		Crawl:        s.config.Crawl,
+	}
+	if run != nil {
+		config.RunID = run.ID
+	}
+	processor := NewParallelProcessor(config)
+
+	job.mu.Lock()
+	job.processor = processor
+	job.RunID = config.RunID
+	job.mu.Unlock()
+
+	err := processor.ProcessJobs(ctx, job.URLs)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.finish(JobCancelled, nil)
+	case err != nil:
+		job.finish(JobFailed, err)
+	default:
+		job.finish(JobCompleted, nil)
+	}
+
+	if run != nil {
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Processed, run.Scanned, run.Excluded, run.Skipped, run.Errors = processor.Counts()
+		if err := finishScanRun(run); err != nil {
+			logger.Printf("Error recording end of scan run %d: %v\n", run.ID, err)
+		}
+	}
+	logger.Printf("Scan job %s finished: %s\n", job.ID, job.Status().State)
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Printf("Error encoding API response: %v\n", err)
+	}
+}
+
+// writeError writes an error message as a JSON response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (s *APIServer) healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+// createScanHandler accepts a JSON body {"urls": [...]} or a URL list in
+// any of the input formats of the command line
+func (s *APIServer) createScanHandler(w http.ResponseWriter, r *http.Request) {
+	urls, err := parseURLList(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(urls) == 0 {
+		writeError(w, http.StatusBadRequest, "no URLs submitted")
+		return
+	}
+
+	job, err := s.Submit(urls)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *APIServer) listScansHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	jobs := make([]*ScanJob, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id])
+	}
+	s.mu.RUnlock()
+
+	statuses := make([]jobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *APIServer) getScanHandler(w http.ResponseWriter, r *http.Request) {
+	job := s.Job(mux.Vars(r)["id"])
+	if job == nil {
+		writeError(w, http.StatusNotFound, "scan job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+// cancelScanHandler stops a queued or running job; results processed so
+// far are kept
+func (s *APIServer) cancelScanHandler(w http.ResponseWriter, r *http.Request) {
+	job := s.Job(mux.Vars(r)["id"])
+	if job == nil {
+		writeError(w, http.StatusNotFound, "scan job not found")
+		return
+	}
+
+	job.mu.Lock()
+	switch job.State {
+	case JobQueued:
+		finishedAt := time.Now()
+		job.State = JobCancelled
+		job.FinishedAt = &finishedAt
+	case JobRunning:
+		job.cancel()
+	}
+	job.mu.Unlock()
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+// getScanResultsHandler returns one record per processed URL, in the same
+// format as -output json
+func (s *APIServer) getScanResultsHandler(w http.ResponseWriter, r *http.Request) {
+	job := s.Job(mux.Vars(r)["id"])
+	if job == nil {
+		writeError(w, http.StatusNotFound, "scan job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Results())
+}
+
+// libraryStatisticsHandler returns library usage from the database,
+// optionally limited to the scan run of a job (?job=id) or a run (?run=id)
+func (s *APIServer) libraryStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.config.UseDB {
+		writeError(w, http.StatusServiceUnavailable, "library statistics require database storage (start with -db)")
+		return
+	}
+
+	var runID int64
+	if value := r.URL.Query().Get("run"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid scan run ID %q", value))
+			return
+		}
+		runID = id
+	}
+	if id := r.URL.Query().Get("job"); id != "" {
+		job := s.Job(id)
+		if job == nil {
+			writeError(w, http.StatusNotFound, "scan job not found")
+			return
+		}
+		if runID = job.Status().RunID; runID == 0 {
+			writeError(w, http.StatusConflict, "scan job has no recorded scan run yet")
+			return
+		}
+	}
+
+	libraries, err := getLibraryStatistics(runID)
+	if err != nil {
+		logger.Printf("Error retrieving library statistics: %v\n", err)
+		writeError(w, http.StatusInternalServerError, "error retrieving library statistics")
+		return
+	}
+
+	records := make([]libraryRecord, 0, len(libraries))
+	for _, lib := range libraries {
+		records = append(records, libraryRecord{
+			Name:         lib.Name,
+			Version:      lib.Version,
+			Checksum:     lib.Checksum,
+			Count:        lib.Count,
+			IdentifiedBy: lib.IdentifiedBy,
+		})
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// parseURLList reads the URLs of a scan submission
+func parseURLList(w http.ResponseWriter, r *http.Request) ([]URLJob, error) {
+	body := http.MaxBytesReader(w, r.Body, apiMaxBodySize)
+	defer body.Close()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req scanRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		if len(req.URLs) == 0 {
+			return nil, nil
+		}
+		return parseURLInput(req.URLs, InputJSON)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading URL list: %v", err)
+	}
+	return parseURLInput(content, "")
+}
+
+// runServeCommand implements "netweather serve" and returns the process
+// exit code once the server has shut down
+func runServeCommand(args []string, config ServeConfig) int {
+	if len(args) > 0 {
+		fmt.Println("Usage: netweather serve [-listen addr] [-db db-options] [-workers n]")
+		return 1
+	}
+
+	server := NewAPIServer(config)
+	router, err := server.Router()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go server.runJobs(ctx)
+
+	httpServer := &http.Server{
+		Addr:              config.Listen,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Printf("Error shutting down API server: %v\n", err)
+		}
+	}()
+
+	logger.Printf("API server listening on %s\n", config.Listen)
+	fmt.Printf("API server listening on %s (dashboard at /)\n", config.Listen)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error running API server: %v\n", err)
+		return 1
+	}
+	logger.Println("API server stopped")
+	return 0
+}
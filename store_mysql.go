@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDialect provides the MySQL/MariaDB schema
+type mysqlDialect struct{}
+
+// newMySQLStore connects to a MySQL or MariaDB server
+func newMySQLStore(config DBConfig) (Store, error) {
+	if config.User == "" || config.Name == "" {
+		return nil, fmt.Errorf("database user and name must be provided via command line or environment variables")
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", config.User, config.Password, config.Host, config.Port, config.Name)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: mysqlDialect{}}, nil
+}
+
+// Rebind keeps MySQL's native ? placeholders
+func (mysqlDialect) Rebind(query string) string {
+	return questionRebind(query)
+}
+
+// Name selects the migrations/mysql directory
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+// IsDuplicateObject reports MySQL's duplicate column (1060), duplicate index
+// (1061) and duplicate foreign key (1826, or 1022/121 on older servers)
+// errors, which MySQL cannot avoid with IF NOT EXISTS. MySQL commits DDL
+// implicitly, so re-running a half-applied migration meets these objects.
+func (mysqlDialect) IsDuplicateObject(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	switch mysqlErr.Number {
+	case 1060, 1061, 1826, 1022, 121:
+		return true
+	}
+	return false
+}
+
+// ReturningID is false, the driver reports LastInsertId
+func (mysqlDialect) ReturningID() bool {
+	return false
+}
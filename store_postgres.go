@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresDialect provides the PostgreSQL schema
+type postgresDialect struct{}
+
+// newPostgresStore connects to a PostgreSQL server
+func newPostgresStore(config DBConfig) (Store, error) {
+	if config.User == "" || config.Name == "" {
+		return nil, fmt.Errorf("database user and name must be provided via command line or environment variables")
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		quoteConnValue(config.Host), quoteConnValue(config.Port), quoteConnValue(config.User),
+		quoteConnValue(config.Password), quoteConnValue(config.Name), quoteConnValue(config.SSLMode))
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: postgresDialect{}}, nil
+}
+
+// quoteConnValue quotes a value for a libpq key=value connection string
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Rebind converts ? placeholders to PostgreSQL's $1, $2, ... syntax
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Name selects the migrations/postgres directory
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+// IsDuplicateObject is never needed for PostgreSQL: its migrations use
+// IF NOT EXISTS throughout, and a failed statement aborts the transaction anyway
+func (postgresDialect) IsDuplicateObject(err error) bool {
+	return false
+}
+
+// ReturningID is true, lib/pq does not support LastInsertId
+func (postgresDialect) ReturningID() bool {
+	return true
+}
@@ -0,0 +1,880 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect captures what differs between the SQL databases we support
+type sqlDialect interface {
+	// Name identifies the dialect and selects its migrations directory
+	Name() string
+	// IsDuplicateObject reports whether err means a column or index already
+	// exists, so migrations can run against tables created before versioning
+	IsDuplicateObject(err error) bool
+	// Rebind converts ?-style placeholders to the dialect's placeholder syntax
+	Rebind(query string) string
+	// ReturningID reports whether new row IDs must be read with RETURNING id
+	// because the driver does not support LastInsertId
+	ReturningID() bool
+}
+
+// sqlStore implements Store on top of database/sql. The queries are shared
+// between backends; the dialect supplies schema and placeholder differences.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// sqlRunner runs statements on the database or within a transaction
+type sqlRunner interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert runs an INSERT statement and returns the ID of the new row
+func (s *sqlStore) insert(query string, args ...interface{}) (int64, error) {
+	return s.insertWith(s.db, query, args...)
+}
+
+// insertWith runs an INSERT statement with runner, e.g. a transaction, and
+// returns the ID of the new row
+func (s *sqlStore) insertWith(runner sqlRunner, query string, args ...interface{}) (int64, error) {
+	query = s.dialect.Rebind(query)
+	if s.dialect.ReturningID() {
+		var id int64
+		err := runner.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := runner.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// exec runs a statement after adapting its placeholders to the dialect
+func (s *sqlStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.dialect.Rebind(query), args...)
+}
+
+// queryRow runs a single-row query after adapting its placeholders to the dialect
+func (s *sqlStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.dialect.Rebind(query), args...)
+}
+
+// query runs a query after adapting its placeholders to the dialect
+func (s *sqlStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.Rebind(query), args...)
+}
+
+// CreateSchema brings the database schema up to date
+func (s *sqlStore) CreateSchema() error {
+	_, err := s.Migrate()
+	return err
+}
+
+// Migrate applies all pending migrations in version order and returns how
+// many were applied
+func (s *sqlStore) Migrate() (int, error) {
+	migrations, err := loadMigrations(s.dialect.Name())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		if err := s.applyMigration(migration); err != nil {
+			return count, fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+		logger.Printf("Applied migration %04d_%s\n", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration runs a single migration and records it in schema_migrations.
+// MySQL commits DDL implicitly, so the transaction only guards the other dialects.
+func (s *sqlStore) applyMigration(migration Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.Statements {
+		if _, err := tx.Exec(statement); err != nil {
+			// Databases created before versioned migrations may already have the column
+			if s.dialect.IsDuplicateObject(err) {
+				logger.Printf("Migration %04d: skipping existing object: %v\n", migration.Version, err)
+				continue
+			}
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(s.dialect.Rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), migration.Version, migration.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appliedMigrations returns the applied migration versions with their timestamps
+func (s *sqlStore) appliedMigrations() (map[int]nullTime, error) {
+	if _, err := s.db.Exec(schemaMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]nullTime)
+	for rows.Next() {
+		var version int
+		var appliedAt nullTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func (s *sqlStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, done := applied[migration.Version]; done {
+			status.Applied = true
+			if appliedAt.Valid {
+				t := appliedAt.Time
+				status.AppliedAt = &t
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// maxInsertRows bounds the rows of one multi-row INSERT, keeping statements
+// below the placeholder limits of all backends
+const maxInsertRows = 500
+
+// scanResultColumns are the scan_results columns written for a ScanResult
+var scanResultColumns = []string{
+	"url", "script_url", "checksum", "library_name", "library_version", "identified_by", "is_inline",
+	"cve_ids", "severity", "fixed_in", "run_id", "owner", "tags", "root_url", "date",
+}
+
+// reachabilityColumns are the url_reachability columns written for a URLReachability
+var reachabilityColumns = []string{
+	"original_url", "http_available", "https_available", "http_status_code", "https_status_code",
+	"http_redirect_url", "https_redirect_url", "final_url", "run_id",
+}
+
+// insertQuery returns an INSERT statement for rows rows of the columns
+func insertQuery(table string, columns []string, rows int) string {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = placeholders
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(values, ", ")
+}
+
+// insertRows inserts rows into a table with multi-row INSERTs of up to
+// maxInsertRows rows, all within a single transaction
+func (s *sqlStore) insertRows(table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(rows); start += maxInsertRows {
+		chunk := rows[start:min(start+maxInsertRows, len(rows))]
+		args := make([]interface{}, 0, len(chunk)*len(columns))
+		for _, row := range chunk {
+			args = append(args, row...)
+		}
+		if _, err := tx.Exec(s.dialect.Rebind(insertQuery(table, columns, len(chunk))), args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanResultValues returns the scan_results values of a result in the order
+// of scanResultColumns
+func scanResultValues(result ScanResult) []interface{} {
+	// Vulnerability columns stay NULL for libraries without known advisories
+	var cveIDs, severity, fixedIn interface{}
+	if len(result.Vulnerabilities) > 0 {
+		ids, sev, fixed := summarizeVulnerabilities(result.Vulnerabilities)
+		cveIDs = strings.Join(ids, ",")
+		severity = sev
+		if fixed != "" {
+			fixedIn = fixed
+		}
+	}
+
+	// Input metadata stays NULL for plain URL lists
+	var owner, tags interface{}
+	if result.Owner != "" {
+		owner = result.Owner
+	}
+	if len(result.Tags) > 0 {
+		tags = strings.Join(result.Tags, ",")
+	}
+
+	// The root is only stored for pages other than the scanned URL
+	var rootURL interface{}
+	if result.RootURL != "" && result.RootURL != result.URL {
+		rootURL = result.RootURL
+	}
+
+	return []interface{}{result.URL, result.ScriptURL, result.Checksum, result.LibraryName, result.LibraryVersion, result.IdentifiedBy, result.IsInline, cveIDs, severity, fixedIn, nullableID(result.RunID), owner, tags, rootURL, time.Now().Format("2006-01-02")}
+}
+
+// reachabilityValues returns the url_reachability values of a check in the
+// order of reachabilityColumns
+func reachabilityValues(result *URLReachability) []interface{} {
+	// Convert empty strings to NULL for database storage
+	var httpRedirect, httpsRedirect, finalURL interface{}
+	if result.HTTPRedirectURL != "" {
+		httpRedirect = result.HTTPRedirectURL
+	}
+	if result.HTTPSRedirectURL != "" {
+		httpsRedirect = result.HTTPSRedirectURL
+	}
+	if result.FinalURL != "" {
+		finalURL = result.FinalURL
+	}
+
+	// Convert zero status codes to NULL
+	var httpStatus, httpsStatus interface{}
+	if result.HTTPStatusCode > 0 {
+		httpStatus = result.HTTPStatusCode
+	}
+	if result.HTTPSStatusCode > 0 {
+		httpsStatus = result.HTTPSStatusCode
+	}
+
+	return []interface{}{result.OriginalURL, result.HTTPAvailable, result.HTTPSAvailable,
+		httpStatus, httpsStatus, httpRedirect, httpsRedirect, finalURL, nullableID(result.RunID)}
+}
+
+// StoreResult stores a scan result in the database
+func (s *sqlStore) StoreResult(result ScanResult) error {
+	_, err := s.exec(insertQuery("scan_results", scanResultColumns, 1), scanResultValues(result)...)
+	return err
+}
+
+// StoreResults stores scan results in a single transaction
+func (s *sqlStore) StoreResults(results []ScanResult) error {
+	rows := make([][]interface{}, len(results))
+	for i, result := range results {
+		rows[i] = scanResultValues(result)
+	}
+	return s.insertRows("scan_results", scanResultColumns, rows)
+}
+
+// StoreURLReachability stores URL reachability information in the database
+func (s *sqlStore) StoreURLReachability(result *URLReachability) error {
+	_, err := s.exec(insertQuery("url_reachability", reachabilityColumns, 1), reachabilityValues(result)...)
+	return err
+}
+
+// StoreURLReachabilities stores reachability checks in a single transaction
+func (s *sqlStore) StoreURLReachabilities(results []*URLReachability) error {
+	rows := make([][]interface{}, len(results))
+	for i, result := range results {
+		rows[i] = reachabilityValues(result)
+	}
+	return s.insertRows("url_reachability", reachabilityColumns, rows)
+}
+
+// StoreBatchID records a newly created nmap batch
+func (s *sqlStore) StoreBatchID(batchID, url string, runID int64) error {
+	query := "INSERT INTO nmap_batches (batch_id, url, status, run_id, created_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.exec(query, batchID, url, BatchRunning, nullableID(runID), time.Now())
+	return err
+}
+
+// UpdateBatchStatus records the outcome of a batch and its raw nmap XML,
+// which stays NULL if the batch produced none
+func (s *sqlStore) UpdateBatchStatus(batchID, status, results string) error {
+	var xml interface{}
+	if results != "" {
+		xml = results
+	}
+	_, err := s.exec("UPDATE nmap_batches SET status = ?, results = ? WHERE batch_id = ?", status, xml, batchID)
+	return err
+}
+
+// StoreNmapResults stores the hosts a batch found for a URL together with
+// their open ports in a single transaction
+func (s *sqlStore) StoreNmapResults(batchID, url string, runID int64, results []NmapResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, host := range results {
+		hostID, err := s.insertWith(tx, "INSERT INTO nmap_hosts (batch_id, url, ip, hostname, run_id, scanned_at) VALUES (?, ?, ?, ?, ?, ?)",
+			batchID, url, host.IP, host.Hostname, nullableID(runID), host.ScanTime)
+		if err != nil {
+			return fmt.Errorf("error storing host %s: %v", host.IP, err)
+		}
+		for _, port := range host.OpenPorts {
+			number, err := strconv.Atoi(port.Port)
+			if err != nil {
+				return fmt.Errorf("invalid port %q of host %s", port.Port, host.IP)
+			}
+			query := "INSERT INTO nmap_ports (host_id, port, protocol, state, service, product, version) VALUES (?, ?, ?, ?, ?, ?, ?)"
+			if _, err := tx.Exec(s.dialect.Rebind(query), hostID, number, port.Protocol, port.State, port.Service, port.Product, port.Version); err != nil {
+				return fmt.Errorf("error storing port %s of host %s: %v", port.Port, host.IP, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// StartScanRun records a new scan run and assigns its ID
+func (s *sqlStore) StartScanRun(run *ScanRun) error {
+	query := "INSERT INTO scan_runs (started_at, input_file, flags, workers, total_urls) VALUES (?, ?, ?, ?, ?)"
+	id, err := s.insert(query, run.StartedAt, run.InputFile, run.Flags, run.Workers, run.TotalURLs)
+	if err != nil {
+		return err
+	}
+	run.ID = id
+	return nil
+}
+
+// FinishScanRun stores the end time and counters of a scan run
+func (s *sqlStore) FinishScanRun(run *ScanRun) error {
+	query := `UPDATE scan_runs
+		SET finished_at = ?, processed = ?, scanned = ?, excluded = ?, skipped = ?, errors = ?
+		WHERE id = ?`
+	var finishedAt interface{}
+	if run.FinishedAt != nil {
+		finishedAt = *run.FinishedAt
+	}
+	_, err := s.exec(query, finishedAt, run.Processed, run.Scanned, run.Excluded, run.Skipped, run.Errors, run.ID)
+	return err
+}
+
+// scanRunColumns lists the scan_runs columns read by scanScanRun
+const scanRunColumns = "id, started_at, finished_at, COALESCE(input_file, ''), COALESCE(flags, ''), COALESCE(workers, 0), total_urls, processed, scanned, excluded, skipped, errors"
+
+// scanScanRun reads a scan_runs row selected with scanRunColumns
+func scanScanRun(row interface{ Scan(...interface{}) error }) (*ScanRun, error) {
+	var run ScanRun
+	var startedAt, finishedAt nullTime
+	err := row.Scan(&run.ID, &startedAt, &finishedAt, &run.InputFile, &run.Flags, &run.Workers,
+		&run.TotalURLs, &run.Processed, &run.Scanned, &run.Excluded, &run.Skipped, &run.Errors)
+	if err != nil {
+		return nil, err
+	}
+	run.StartedAt = startedAt.Time
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+// ScanRuns retrieves the most recent scan runs, newest first
+func (s *sqlStore) ScanRuns(limit int) ([]ScanRun, error) {
+	rows, err := s.query("SELECT "+scanRunColumns+" FROM scan_runs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ScanRun
+	for rows.Next() {
+		run, err := scanScanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// ScanRun retrieves a single scan run
+func (s *sqlStore) ScanRun(id int64) (*ScanRun, error) {
+	return scanScanRun(s.queryRow("SELECT "+scanRunColumns+" FROM scan_runs WHERE id = ?", id))
+}
+
+// ScanResults returns the stored scan results of a run, or of all runs for
+// runID 0, ordered by site and script. Stored advisory columns are returned
+// as a single summarized Vulnerability.
+func (s *sqlStore) ScanResults(runID int64) ([]ScanResult, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT url, script_url, checksum, COALESCE(library_name, ''), COALESCE(library_version, ''),
+			COALESCE(identified_by, ''), COALESCE(is_inline, FALSE), COALESCE(cve_ids, ''),
+			COALESCE(severity, ''), COALESCE(fixed_in, ''), COALESCE(run_id, 0), COALESCE(owner, ''),
+			COALESCE(tags, ''), COALESCE(root_url, ''), scanned_at
+		FROM scan_results
+		WHERE ` + run + `
+		ORDER BY url, script_url, id
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ScanResult
+	for rows.Next() {
+		var r ScanResult
+		var cveIDs, severity, fixedIn, tags string
+		var scannedAt nullTime
+		if err := rows.Scan(&r.URL, &r.ScriptURL, &r.Checksum, &r.LibraryName, &r.LibraryVersion,
+			&r.IdentifiedBy, &r.IsInline, &cveIDs, &severity, &fixedIn, &r.RunID, &r.Owner, &tags, &r.RootURL, &scannedAt); err != nil {
+			return nil, err
+		}
+		if tags != "" {
+			r.Tags = strings.Split(tags, ",")
+		}
+		if cveIDs != "" {
+			r.Vulnerabilities = []Vulnerability{{
+				Identifiers: strings.Split(cveIDs, ","),
+				Severity:    severity,
+				FixedIn:     fixedIn,
+			}}
+		}
+		r.ScannedAt = scannedAt.Time
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// NmapBatches returns the port scan batches of a run, or of all runs for runID 0
+func (s *sqlStore) NmapBatches(runID int64) ([]NmapBatch, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT batch_id, url, status, COALESCE(results, ''), COALESCE(run_id, 0), created_at
+		FROM nmap_batches
+		WHERE ` + run + `
+		ORDER BY url, created_at
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []NmapBatch
+	for rows.Next() {
+		var batch NmapBatch
+		var createdAt nullTime
+		if err := rows.Scan(&batch.BatchID, &batch.URL, &batch.Status, &batch.Results, &batch.RunID, &createdAt); err != nil {
+			return nil, err
+		}
+		batch.CreatedAt = createdAt.Time
+		batches = append(batches, batch)
+	}
+	return batches, rows.Err()
+}
+
+// URLReachabilityChecks returns the stored reachability checks, newest first
+func (s *sqlStore) URLReachabilityChecks(runID int64) ([]URLReachability, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT original_url, http_available, https_available, COALESCE(http_status_code, 0),
+			COALESCE(https_status_code, 0), COALESCE(http_redirect_url, ''), COALESCE(https_redirect_url, ''),
+			COALESCE(final_url, ''), COALESCE(run_id, 0), scanned_at
+		FROM url_reachability
+		WHERE ` + run + `
+		ORDER BY scanned_at DESC, id DESC
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []URLReachability
+	for rows.Next() {
+		var r URLReachability
+		var scannedAt nullTime
+		if err := rows.Scan(&r.OriginalURL, &r.HTTPAvailable, &r.HTTPSAvailable, &r.HTTPStatusCode, &r.HTTPSStatusCode,
+			&r.HTTPRedirectURL, &r.HTTPSRedirectURL, &r.FinalURL, &r.RunID, &scannedAt); err != nil {
+			return nil, err
+		}
+		r.ScannedAt = scannedAt.Time
+		checks = append(checks, r)
+	}
+	return checks, rows.Err()
+}
+
+// LookupChecksum checks if we have already identified a script with this checksum
+func (s *sqlStore) LookupChecksum(ctx context.Context, checksum string) (*LibraryInfo, error) {
+	query := `
+		SELECT library_name, library_version, identified_by, checksum
+		FROM scan_results
+		WHERE checksum = ? AND library_name IS NOT NULL AND library_name != 'unknown'
+		LIMIT 1
+	`
+
+	var name, version, method, dbChecksum string
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(query), checksum).Scan(&name, &version, &method, &dbChecksum)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LibraryInfo{
+		Name:     name,
+		Version:  version,
+		Checksum: dbChecksum,
+		Method:   method,
+	}, nil
+}
+
+// runFilter returns a condition restricting rows to a scan run, or matching
+// all rows for runID 0, together with its arguments
+func runFilter(runID int64) (string, []interface{}) {
+	if runID == 0 {
+		return "1 = 1", nil
+	}
+	return "run_id = ?", []interface{}{runID}
+}
+
+// OverallStatistics retrieves overall statistics from the database
+func (s *sqlStore) OverallStatistics(runID int64) (*Statistics, error) {
+	stats := &Statistics{}
+	run, args := runFilter(runID)
+
+	// Get total unique URLs
+	err := s.queryRow("SELECT COUNT(DISTINCT url) FROM scan_results WHERE "+run, args...).Scan(&stats.TotalURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get total scripts
+	err = s.queryRow("SELECT COUNT(*) FROM scan_results WHERE "+run, args...).Scan(&stats.TotalScripts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get unique libraries (excluding Unknown and empty)
+	err = s.queryRow("SELECT COUNT(DISTINCT library_name) FROM scan_results WHERE library_name IS NOT NULL AND library_name != '' AND library_name != 'Unknown' AND "+run, args...).Scan(&stats.UniqueLibraries)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get first and last scan times
+	var firstScan, lastScan nullTime
+	err = s.queryRow("SELECT MIN(scanned_at), MAX(scanned_at) FROM scan_results WHERE "+run, args...).Scan(&firstScan, &lastScan)
+	if err != nil {
+		return nil, err
+	}
+
+	if firstScan.Valid {
+		stats.FirstScan = &firstScan.Time
+	}
+	if lastScan.Valid {
+		stats.LastScan = &lastScan.Time
+	}
+
+	return stats, nil
+}
+
+// LibraryStatistics retrieves library usage statistics
+func (s *sqlStore) LibraryStatistics(runID int64) ([]LibraryUsage, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT
+			library_name,
+			COALESCE(library_version, '') as library_version,
+			checksum,
+			COUNT(*) as count,
+			MAX(identified_by) as identified_by
+		FROM scan_results
+		WHERE library_name IS NOT NULL AND library_name != '' AND ` + run + `
+		GROUP BY library_name, library_version, checksum
+		ORDER BY count DESC, library_name ASC, library_version ASC
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var libraries []LibraryUsage
+	for rows.Next() {
+		var lib LibraryUsage
+		if err := rows.Scan(&lib.Name, &lib.Version, &lib.Checksum, &lib.Count, &lib.IdentifiedBy); err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, lib)
+	}
+
+	return libraries, rows.Err()
+}
+
+// VulnerabilityStatistics retrieves library versions with known advisories
+func (s *sqlStore) VulnerabilityStatistics(runID int64) ([]VulnerableLibrary, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT
+			library_name,
+			COALESCE(library_version, '') as library_version,
+			cve_ids,
+			COALESCE(severity, '') as severity,
+			COALESCE(fixed_in, '') as fixed_in,
+			COUNT(DISTINCT url) as sites
+		FROM scan_results
+		WHERE cve_ids IS NOT NULL AND cve_ids != '' AND ` + run + `
+		GROUP BY library_name, library_version, cve_ids, severity, fixed_in
+		ORDER BY sites DESC, library_name ASC, library_version ASC
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var libraries []VulnerableLibrary
+	for rows.Next() {
+		var lib VulnerableLibrary
+		if err := rows.Scan(&lib.Name, &lib.Version, &lib.CVEIDs, &lib.Severity, &lib.FixedIn, &lib.Sites); err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, lib)
+	}
+
+	return libraries, rows.Err()
+}
+
+// RecentScans retrieves the most recent scans
+func (s *sqlStore) RecentScans(limit int, runID int64) ([]RecentScan, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT url, MAX(scanned_at) as last_scan
+		FROM scan_results
+		WHERE ` + run + `
+		GROUP BY url
+		ORDER BY last_scan DESC
+		LIMIT ?
+	`
+
+	rows, err := s.query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []RecentScan
+	for rows.Next() {
+		var scan RecentScan
+		var scannedAt nullTime
+		if err := rows.Scan(&scan.URL, &scannedAt); err != nil {
+			return nil, err
+		}
+		scan.ScannedAt = scannedAt.Time
+		scans = append(scans, scan)
+	}
+
+	return scans, rows.Err()
+}
+
+// NmapBatchStatistics retrieves nmap batch statistics
+func (s *sqlStore) NmapBatchStatistics(runID int64) (map[string]int, error) {
+	run, args := runFilter(runID)
+	query := `
+		SELECT status, COUNT(*) as count
+		FROM nmap_batches
+		WHERE ` + run + `
+		GROUP BY status
+	`
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats[status] = count
+	}
+
+	return stats, rows.Err()
+}
+
+// PortStatistics counts the hosts that were port scanned and, per port, the
+// hosts it was found open on. Hosts are counted by IP address once, however
+// many URLs or batches they were scanned for.
+func (s *sqlStore) PortStatistics(runID int64) (*PortScanStats, error) {
+	stats := &PortScanStats{}
+	run, args := runFilter(runID)
+
+	query := "SELECT COUNT(DISTINCT ip) FROM nmap_hosts WHERE " + run
+	if err := s.queryRow(query, args...).Scan(&stats.HostsScanned); err != nil {
+		return nil, err
+	}
+	query = `
+		SELECT COUNT(DISTINCT h.ip)
+		FROM nmap_hosts h
+		WHERE ` + run + ` AND EXISTS (SELECT 1 FROM nmap_ports p WHERE p.host_id = h.id)
+	`
+	if err := s.queryRow(query, args...).Scan(&stats.HostsWithOpenPorts); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT p.port, p.protocol, COALESCE(MAX(p.service), ''), COUNT(DISTINCT h.ip) as hosts
+		FROM nmap_ports p
+		JOIN nmap_hosts h ON h.id = p.host_id
+		WHERE ` + run + `
+		GROUP BY p.port, p.protocol
+		ORDER BY hosts DESC, p.port, p.protocol
+	`
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage OpenPortUsage
+		if err := rows.Scan(&usage.Port, &usage.Protocol, &usage.Service, &usage.Hosts); err != nil {
+			return nil, err
+		}
+		stats.Ports = append(stats.Ports, usage)
+	}
+	return stats, rows.Err()
+}
+
+// URLReachabilityStatistics retrieves URL reachability statistics
+func (s *sqlStore) URLReachabilityStatistics(runID int64) (*URLReachabilityStats, error) {
+	stats := &URLReachabilityStats{}
+	run, args := runFilter(runID)
+
+	counts := []struct {
+		where string
+		dest  *int
+	}{
+		{"1 = 1", &stats.TotalChecked},
+		{"http_available = TRUE AND https_available = FALSE", &stats.HTTPOnlyCount},
+		{"http_available = FALSE AND https_available = TRUE", &stats.HTTPSOnlyCount},
+		{"http_available = TRUE AND https_available = TRUE", &stats.BothProtocolsCount},
+		{"http_available = FALSE AND https_available = FALSE", &stats.UnreachableCount},
+		{"(http_redirect_url IS NOT NULL OR https_redirect_url IS NOT NULL)", &stats.RedirectCount},
+	}
+
+	for _, c := range counts {
+		query := "SELECT COUNT(*) FROM url_reachability WHERE " + c.where + " AND " + run
+		if err := s.queryRow(query, args...).Scan(c.dest); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// Close closes the database connection
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// nullTime scans timestamps from drivers that return either time.Time values
+// or text (SQLite returns aggregates such as MAX(scanned_at) as strings)
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// timestampLayouts lists the text formats databases use for timestamps
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z",
+	"2006-01-02",
+}
+
+// Scan implements sql.Scanner
+func (nt *nullTime) Scan(value interface{}) error {
+	nt.Time, nt.Valid = time.Time{}, false
+
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		nt.Time, nt.Valid = v, true
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", value)
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			nt.Time, nt.Valid = t, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", text)
+}
+
+// nullableID converts an unset (zero) ID to NULL
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// questionRebind leaves ?-style placeholders untouched
+func questionRebind(query string) string {
+	return query
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect provides the SQLite schema for local, server-less scans
+type sqliteDialect struct{}
+
+// newSQLiteStore opens (and creates if needed) a SQLite database file
+func newSQLiteStore(config DBConfig) (Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("database path must be provided for sqlite")
+	}
+
+	// _time_format=sqlite stores time.Time arguments in a format nullTime can parse
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite", config.Path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialize access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: sqliteDialect{}}, nil
+}
+
+// Rebind keeps SQLite's native ? placeholders
+func (sqliteDialect) Rebind(query string) string {
+	return questionRebind(query)
+}
+
+// Name selects the migrations/sqlite directory
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+// IsDuplicateObject reports SQLite's duplicate column error, SQLite has no
+// ADD COLUMN IF NOT EXISTS
+func (sqliteDialect) IsDuplicateObject(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate column name")
+}
+
+// ReturningID is false, the driver reports LastInsertId
+func (sqliteDialect) ReturningID() bool {
+	return false
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Vulnerability describes a known advisory affecting an identified library version
+type Vulnerability struct {
+	Identifiers []string // CVE IDs, or the GitHub advisory ID when no CVE is assigned
+	Severity    string   // low, medium, high or critical
+	FixedIn     string   // First version that is no longer affected
+	Summary     string
+	Info        []string // Reference URLs
+}
+
+// retireVulnerability mirrors a vulnerability entry of retire.js' jsrepository.json
+type retireVulnerability struct {
+	AtOrAbove   string `json:"atOrAbove"`
+	Above       string `json:"above"`
+	Below       string `json:"below"`
+	Severity    string `json:"severity"`
+	Identifiers struct {
+		CVE      []string `json:"CVE"`
+		GithubID string   `json:"githubID"`
+		Summary  string   `json:"summary"`
+	} `json:"identifiers"`
+	Info []string `json:"info"`
+}
+
+// retireEntry mirrors a library entry of retire.js' jsrepository.json
+type retireEntry struct {
+	NpmName         string                `json:"npmname"`
+	BowerName       []string              `json:"bowername"`
+	Vulnerabilities []retireVulnerability `json:"vulnerabilities"`
+}
+
+// VulnerabilityDB holds advisories loaded from a retire.js-style repository file
+type VulnerabilityDB struct {
+	path     string
+	entries  map[string][]retireVulnerability // keyed by lowercase retire.js, npm and bower name
+	npmNames map[string]string                // npm package by lowercase retire.js, npm and bower name
+	mutex    sync.RWMutex
+	loaded   bool
+}
+
+var vulnDB = &VulnerabilityDB{
+	path:     "jsrepository.json",
+	entries:  make(map[string][]retireVulnerability),
+	npmNames: make(map[string]string),
+}
+
+// SetVulnerabilityDBPath configures which advisory file to load
+func SetVulnerabilityDBPath(path string) {
+	vulnDB.mutex.Lock()
+	defer vulnDB.mutex.Unlock()
+	vulnDB.path = path
+	vulnDB.loaded = false // Force reload from the new location
+}
+
+// load reads the advisory file once. A missing file simply disables matching.
+func (vdb *VulnerabilityDB) load() error {
+	vdb.mutex.Lock()
+	defer vdb.mutex.Unlock()
+
+	if vdb.loaded {
+		return nil
+	}
+	vdb.loaded = true
+	vdb.entries = make(map[string][]retireVulnerability)
+	vdb.npmNames = make(map[string]string)
+
+	data, err := os.ReadFile(vdb.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Printf("Vulnerability database %s not found, vulnerability matching disabled\n", vdb.path)
+			return nil
+		}
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("error parsing %s: %v", vdb.path, err)
+	}
+
+	for name, rawEntry := range raw {
+		var entry retireEntry
+		if err := json.Unmarshal(rawEntry, &entry); err != nil {
+			logger.Printf("Warning: Skipping invalid entry %q in %s: %v\n", name, vdb.path, err)
+			continue
+		}
+
+		// Index the entry under every name it is known by. The names are
+		// not normalized further: related libraries such as jquery and
+		// jquery-ui must keep separate advisories.
+		names := append([]string{name, entry.NpmName}, entry.BowerName...)
+		seen := make(map[string]bool)
+		for _, n := range names {
+			key := advisoryKey(n)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if entry.NpmName != "" {
+				vdb.npmNames[key] = entry.NpmName
+			}
+			if len(entry.Vulnerabilities) > 0 {
+				vdb.entries[key] = append(vdb.entries[key], entry.Vulnerabilities...)
+			}
+		}
+	}
+
+	logger.Printf("Loaded advisories for %d libraries from %s\n", len(vdb.entries), vdb.path)
+	return nil
+}
+
+// Match returns the advisories affecting the given library version
+func (vdb *VulnerabilityDB) Match(name, version string) []Vulnerability {
+	if name == "" || !isComparableVersion(version) {
+		return nil
+	}
+
+	if err := vdb.load(); err != nil {
+		logger.Printf("Error loading vulnerability database: %v\n", err)
+		return nil
+	}
+
+	vdb.mutex.RLock()
+	defer vdb.mutex.RUnlock()
+
+	var matches []Vulnerability
+	for _, v := range vdb.entries[advisoryKey(name)] {
+		if v.AtOrAbove != "" && compareVersions(version, v.AtOrAbove) < 0 {
+			continue
+		}
+		if v.Above != "" && compareVersions(version, v.Above) <= 0 {
+			continue
+		}
+		if v.Below != "" && compareVersions(version, v.Below) >= 0 {
+			continue
+		}
+
+		ids := append([]string{}, v.Identifiers.CVE...)
+		if len(ids) == 0 && v.Identifiers.GithubID != "" {
+			ids = append(ids, v.Identifiers.GithubID)
+		}
+		matches = append(matches, Vulnerability{
+			Identifiers: ids,
+			Severity:    strings.ToLower(v.Severity),
+			FixedIn:     v.Below,
+			Summary:     v.Identifiers.Summary,
+			Info:        v.Info,
+		})
+	}
+	return matches
+}
+
+// NpmName returns the npm package of a library according to the advisory
+// file, or an empty string if the file names none
+func (vdb *VulnerabilityDB) NpmName(name string) string {
+	if err := vdb.load(); err != nil {
+		logger.Printf("Error loading vulnerability database: %v\n", err)
+		return ""
+	}
+
+	vdb.mutex.RLock()
+	defer vdb.mutex.RUnlock()
+	return vdb.npmNames[advisoryKey(name)]
+}
+
+// advisoryKey is the name under which advisories of a library are indexed
+func advisoryKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// summarizeVulnerabilities condenses a set of advisories into the values stored
+// alongside a scan result: all identifiers, the highest severity and the
+// lowest version that fixes every advisory
+func summarizeVulnerabilities(vulns []Vulnerability) (ids []string, severity, fixedIn string) {
+	seen := make(map[string]bool)
+	for _, v := range vulns {
+		for _, id := range v.Identifiers {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if severityRank(v.Severity) > severityRank(severity) {
+			severity = v.Severity
+		}
+		if v.FixedIn != "" && (fixedIn == "" || compareVersions(v.FixedIn, fixedIn) > 0) {
+			fixedIn = v.FixedIn
+		}
+	}
+	sort.Strings(ids)
+	return ids, severity, fixedIn
+}
+
+// formatVulnerabilities renders advisories as a single line for terminal output
+func formatVulnerabilities(vulns []Vulnerability) string {
+	ids, severity, fixedIn := summarizeVulnerabilities(vulns)
+	line := strings.Join(ids, ", ")
+	if line == "" {
+		line = fmt.Sprintf("%d advisories", len(vulns))
+	}
+	if severity != "" {
+		line += fmt.Sprintf(" (%s)", severity)
+	}
+	if fixedIn != "" {
+		line += fmt.Sprintf(", fixed in %s", fixedIn)
+	}
+	return line
+}
+
+// severityRank orders severities so the worst one can be picked
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "low":
+		return 1
+	case "medium":
+		return 2
+	case "high":
+		return 3
+	case "critical":
+		return 4
+	}
+	return 0
+}
+
+// isComparableVersion reports whether a version string starts with a number
+// and can therefore be compared against advisory ranges
+func isComparableVersion(version string) bool {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	return version != "" && version[0] >= '0' && version[0] <= '9'
+}
+
+// compareVersions compares two semver-like versions and returns -1, 0 or 1.
+// Pre-release versions (1.2.0-rc1) sort before the corresponding release.
+func compareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		var x, y int
+		if i < len(aCore) {
+			x = aCore[i]
+		}
+		if i < len(bCore) {
+			y = bCore[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	}
+	return 1
+}
+
+// splitVersion splits a version into its numeric components and pre-release suffix
+func splitVersion(version string) ([]int, string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.Index(version, "+"); idx != -1 {
+		version = version[:idx] // Build metadata does not affect ordering
+	}
+
+	pre := ""
+	if idx := strings.IndexAny(version, "-"); idx != -1 {
+		pre = version[idx+1:]
+		version = version[:idx]
+	}
+
+	var parts []int
+	for _, p := range strings.Split(version, ".") {
+		// Treat trailing letters (e.g. "1.0.0rc1") as a pre-release marker
+		digits := p
+		for i, r := range p {
+			if r < '0' || r > '9' {
+				digits = p[:i]
+				if pre == "" {
+					pre = p[i:]
+				}
+				break
+			}
+		}
+		n, _ := strconv.Atoi(digits)
+		parts = append(parts, n)
+	}
+	return parts, pre
+}
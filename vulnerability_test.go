@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepository has advisories for jquery and for the separate jquery-ui
+// library, whose version ranges overlap, and an entry without advisories
+const testRepository = `{
+	"jquery": {
+		"npmname": "jquery",
+		"vulnerabilities": [
+			{"below": "3.5.0", "atOrAbove": "1.2.0", "severity": "medium", "identifiers": {"CVE": ["CVE-2020-11022"]}}
+		]
+	},
+	"jquery-ui": {
+		"npmname": "jquery-ui",
+		"bowername": ["jquery-ui", "jqueryui"],
+		"vulnerabilities": [
+			{"below": "1.13.0", "severity": "medium", "identifiers": {"CVE": ["CVE-2021-41182"]}}
+		]
+	},
+	"react": {
+		"npmname": "react",
+		"vulnerabilities": []
+	},
+	"moment.js": {
+		"npmname": "moment",
+		"vulnerabilities": [
+			{"below": "2.29.2", "severity": "high", "identifiers": {"githubID": "GHSA-8hfj-j24r-96c4"}}
+		]
+	}
+}`
+
+func newTestVulnerabilityDB(t *testing.T) *VulnerabilityDB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jsrepository.json")
+	if err := os.WriteFile(path, []byte(testRepository), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &VulnerabilityDB{path: path}
+}
+
+func TestVulnerabilityDBMatch(t *testing.T) {
+	vdb := newTestVulnerabilityDB(t)
+
+	tests := []struct {
+		name    string
+		library string
+		version string
+		want    string // Comma-separated identifiers
+	}{
+		{"jquery in range", "jquery", "1.12.4", "CVE-2020-11022"},
+		{"jquery never gets jquery-ui advisories", "jquery", "1.11.0", "CVE-2020-11022"},
+		{"jquery fixed", "jquery", "3.5.0", ""},
+		{"jquery below atOrAbove", "jquery", "1.1.0", ""},
+		{"jquery-ui in range", "jquery-ui", "1.12.1", "CVE-2021-41182"},
+		{"jquery-ui by bower name", "jqueryui", "1.12.1", "CVE-2021-41182"},
+		{"case insensitive", "jQuery-UI", "1.12.1", "CVE-2021-41182"},
+		{"npm name", "moment", "2.29.1", "GHSA-8hfj-j24r-96c4"},
+		{"repository key", "moment.js", "2.29.1", "GHSA-8hfj-j24r-96c4"},
+		{"unknown version", "jquery", "unknown", ""},
+		{"unknown library", "lodash", "4.17.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, v := range vdb.Match(tt.library, tt.version) {
+				ids = append(ids, v.Identifiers...)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("Match(%q, %q) = %q, want %q", tt.library, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVulnerabilityDBNpmName(t *testing.T) {
+	vdb := newTestVulnerabilityDB(t)
+
+	tests := []struct {
+		library string
+		want    string
+	}{
+		{"jquery", "jquery"},
+		{"jqueryui", "jquery-ui"},
+		{"moment.js", "moment"},
+		{"Moment", "moment"},
+		{"react", "react"},
+		{"mycompany-widgets", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := vdb.NpmName(tt.library); got != tt.want {
+			t.Errorf("NpmName(%q) = %q, want %q", tt.library, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0", "2.0.0", 0},
+		{"2.0.1", "2.0", 1},
+		{"v3.5.0", "3.5.0", 0},
+		{" 3.5.0 ", "3.5.0", 0},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0rc1", "1.0.0", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.0.0-rc1+build", "1.0.0-rc1", 0},
+		{"3.4.1", "3.5.0-beta", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestIsComparableVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"3.7.1", true},
+		{"v2", true},
+		{" 1.0", true},
+		{"", false},
+		{"unknown", false},
+		{"latest", false},
+		{"v", false},
+	}
+	for _, tt := range tests {
+		if got := isComparableVersion(tt.version); got != tt.want {
+			t.Errorf("isComparableVersion(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestVulnerabilityDBMatchRanges(t *testing.T) {
+	advisory := func(atOrAbove, above, below string) retireVulnerability {
+		v := retireVulnerability{AtOrAbove: atOrAbove, Above: above, Below: below}
+		v.Identifiers.CVE = []string{"CVE-TEST"}
+		return v
+	}
+	tests := []struct {
+		name     string
+		advisory retireVulnerability
+		version  string
+		want     bool
+	}{
+		{"below, inside", advisory("", "", "2.0.0"), "1.9.9", true},
+		{"below, at bound", advisory("", "", "2.0.0"), "2.0.0", false},
+		{"below, pre-release of bound", advisory("", "", "2.0.0"), "2.0.0-rc1", true},
+		{"atOrAbove, at bound", advisory("1.2.0", "", "2.0.0"), "1.2.0", true},
+		{"atOrAbove, under bound", advisory("1.2.0", "", "2.0.0"), "1.1.9", false},
+		{"above, at bound", advisory("", "1.2.0", "2.0.0"), "1.2.0", false},
+		{"above, over bound", advisory("", "1.2.0", "2.0.0"), "1.2.1", true},
+		{"above only", advisory("", "1.2.0", ""), "9.0.0", true},
+		{"no bounds", advisory("", "", ""), "0.0.1", true},
+		{"v prefix", advisory("", "", "2.0.0"), "v1.5.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vdb := &VulnerabilityDB{loaded: true, entries: map[string][]retireVulnerability{"lib": {tt.advisory}}}
+			if got := len(vdb.Match("lib", tt.version)) > 0; got != tt.want {
+				t.Errorf("Match(%q) against %+v = %v, want %v", tt.version, tt.advisory, got, tt.want)
+			}
+		})
+	}
+}